@@ -0,0 +1,104 @@
+// Broadcaster en memoria de las novedades de los deliveries.
+// Los comandos publican cada evento guardado y cada posición registrada, y los streams
+// (SSE) se suscriben para enviarlas a los clientes conectados a esta instancia.
+package broadcast
+
+import (
+	"sync"
+
+	"deliverygo/events"
+	"deliverygo/locations"
+)
+
+// subscriberBuffer mensajes que puede acumular un suscriptor lento antes de ser desconectado
+const subscriberBuffer = 64
+
+// Message es una novedad de un delivery, un evento o una posición
+type Message struct {
+	DeliveryId string
+	Event      *events.Event   // Evento guardado, nil si es una posición
+	Ping       *locations.Ping // Posición registrada, nil si es un evento
+}
+
+type subscriber struct {
+	deliveryId string // Vacío si recibe las novedades de todos los deliveries
+	messages   chan *Message
+}
+
+var mutex sync.RWMutex
+var subscribers = map[*subscriber]bool{}
+
+// Subscribe se suscribe a las novedades de un delivery.
+// El canal se cierra al llamar a la función de cancelación o si el suscriptor no consume
+// a tiempo, en ese caso el cliente debe reconectarse.
+func Subscribe(deliveryId string) (<-chan *Message, func()) {
+	return subscribe(&subscriber{
+		deliveryId: deliveryId,
+		messages:   make(chan *Message, subscriberBuffer),
+	})
+}
+
+// SubscribeAll se suscribe a las novedades de todos los deliveries
+func SubscribeAll() (<-chan *Message, func()) {
+	return subscribe(&subscriber{
+		messages: make(chan *Message, subscriberBuffer),
+	})
+}
+
+func subscribe(s *subscriber) (<-chan *Message, func()) {
+	mutex.Lock()
+	subscribers[s] = true
+	mutex.Unlock()
+
+	return s.messages, func() {
+		unsubscribe(s)
+	}
+}
+
+func unsubscribe(s *subscriber) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if subscribers[s] {
+		delete(subscribers, s)
+		close(s.messages)
+	}
+}
+
+// PublishEvent notifica un evento guardado en el stream del delivery
+func PublishEvent(event *events.Event) {
+	publish(&Message{
+		DeliveryId: event.DeliveryId,
+		Event:      event,
+	})
+}
+
+// PublishLocation notifica una posición registrada
+func PublishLocation(ping *locations.Ping) {
+	publish(&Message{
+		DeliveryId: ping.DeliveryId,
+		Ping:       ping,
+	})
+}
+
+func publish(m *Message) {
+	slow := []*subscriber{}
+
+	mutex.RLock()
+	for s := range subscribers {
+		if s.deliveryId != "" && s.deliveryId != m.DeliveryId {
+			continue
+		}
+		select {
+		case s.messages <- m:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	mutex.RUnlock()
+
+	// Los suscriptores que no consumen se desconectan, al reconectar recuperan lo perdido
+	for _, s := range slow {
+		unsubscribe(s)
+	}
+}
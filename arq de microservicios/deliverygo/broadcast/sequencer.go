@@ -0,0 +1,63 @@
+package broadcast
+
+import (
+	"deliverygo/events"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sequencer ordena los eventos de un delivery que recibe un stream. Con comandos concurrentes
+// el broadcaster puede entregar v3 antes que v2: si el evento recibido no es el siguiente a
+// LastVersion, el hueco se completa leyendo el stream del delivery.
+// Los eventos anteriores al versionado tienen versión 0, se envían solo en el historial inicial
+// y se identifican por su id para no repetirlos.
+type Sequencer struct {
+	LastVersion int64 // Versión del último evento enviado
+	load        func() ([]*events.Event, error)
+	sent        map[primitive.ObjectID]bool // Eventos sin versión ya enviados
+}
+
+// NewSequencer crea el Sequencer de un stream que ya envió hasta lastVersion.
+// load lee el stream completo del delivery, ordenado por versión.
+func NewSequencer(lastVersion int64, load func() ([]*events.Event, error)) *Sequencer {
+	return &Sequencer{LastVersion: lastVersion, load: load, sent: map[primitive.ObjectID]bool{}}
+}
+
+// Next retorna, en orden, los eventos que hay que enviar al recibir e. Vacío si ya se envió.
+func (s *Sequencer) Next(e *events.Event) ([]*events.Event, error) {
+	if e.Version <= s.LastVersion {
+		return nil, nil
+	}
+	if e.Version == s.LastVersion+1 {
+		s.LastVersion = e.Version
+		return []*events.Event{e}, nil
+	}
+
+	// Los eventos anteriores a e ya están guardados, e se creó a partir de ellos
+	stream, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s.Missing(stream, e.Version), nil
+}
+
+// Missing retorna los eventos de stream posteriores a LastVersion hasta la versión to inclusive
+// y avanza LastVersion. Se usa también para enviar el historial al conectarse: mientras no se
+// envió ningún evento versionado incluye también los eventos sin versión que no se enviaron.
+func (s *Sequencer) Missing(stream []*events.Event, to int64) []*events.Event {
+	result := []*events.Event{}
+	for _, e := range stream {
+		if e.Version == 0 {
+			if s.LastVersion == 0 && !s.sent[e.ID] {
+				result = append(result, e)
+				s.sent[e.ID] = true
+			}
+			continue
+		}
+		if e.Version > s.LastVersion && (to <= 0 || e.Version <= to) {
+			result = append(result, e)
+			s.LastVersion = e.Version
+		}
+	}
+	return result
+}
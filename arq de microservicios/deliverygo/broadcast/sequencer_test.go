@@ -0,0 +1,88 @@
+package broadcast
+
+import (
+	"testing"
+
+	"deliverygo/events"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func event(version int64) *events.Event {
+	return &events.Event{ID: primitive.NewObjectID(), DeliveryId: "d1", Version: version}
+}
+
+func versions(stream []*events.Event) []int64 {
+	result := []int64{}
+	for _, e := range stream {
+		result = append(result, e.Version)
+	}
+	return result
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMissingIncludesUnversionedEvents(t *testing.T) {
+	// Delivery creado antes del versionado y modificado después
+	stream := []*events.Event{event(0), event(0), event(3), event(4)}
+
+	tests := []struct {
+		name        string
+		lastVersion int64
+		want        []int64
+	}{
+		{"historial completo", 0, []int64{0, 0, 3, 4}},
+		{"reconexión", 3, []int64{4}},
+		{"al día", 4, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSequencer(tt.lastVersion, nil)
+			if got := versions(s.Missing(stream, 0)); !equal(got, tt.want) {
+				t.Errorf("Missing() = %v, se esperaba %v", got, tt.want)
+			}
+			if s.LastVersion != 4 {
+				t.Errorf("LastVersion = %d, se esperaba 4", s.LastVersion)
+			}
+		})
+	}
+}
+
+func TestMissingDoesNotRepeatUnversionedEvents(t *testing.T) {
+	// Stream que todavía no tiene eventos versionados, se vuelve a leer al llegar el primero
+	legacy := []*events.Event{event(0), event(0)}
+	s := NewSequencer(0, nil)
+
+	if got := versions(s.Missing(legacy, 0)); !equal(got, []int64{0, 0}) {
+		t.Fatalf("Missing() = %v", got)
+	}
+	if got := versions(s.Missing(append(legacy, event(1), event(2)), 2)); !equal(got, []int64{1, 2}) {
+		t.Errorf("Missing() = %v, se esperaba [1 2]", got)
+	}
+}
+
+func TestNextFillsGaps(t *testing.T) {
+	stream := []*events.Event{event(0), event(1), event(2), event(3)}
+	s := NewSequencer(1, func() ([]*events.Event, error) { return stream, nil })
+
+	if got, _ := s.Next(stream[1]); len(got) != 0 {
+		t.Errorf("Next(v1) = %v, ya se había enviado", versions(got))
+	}
+	if got, _ := s.Next(stream[3]); !equal(versions(got), []int64{2, 3}) {
+		t.Errorf("Next(v3) = %v, se esperaba [2 3]", versions(got))
+	}
+	if got, _ := s.Next(stream[2]); len(got) != 0 {
+		t.Errorf("Next(v2) = %v, ya se había enviado", versions(got))
+	}
+}
@@ -0,0 +1,195 @@
+// Subcomandos de mantenimiento del binario deliverygo
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"deliverygo/deadletter"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/rabbit/consume"
+	"deliverygo/tools/log"
+)
+
+// runCommand ejecuta un subcomando y retorna el código de salida del proceso
+func runCommand(name string, args []string) int {
+	switch name {
+	case "rebuild-projections":
+		return rebuildProjections(args)
+	case "dlq":
+		return deadLetters(args)
+	case "dedupe-deliveries":
+		return dedupeDeliveries(args)
+	}
+
+	fmt.Println("Comando desconocido:", name)
+	fmt.Println("Uso: deliverygo [rebuild-projections [-force | -deliveryId <id>]]")
+	fmt.Println("     deliverygo dlq <list|requeue|discard|audit> [opciones]")
+	fmt.Println("     deliverygo dedupe-deliveries [-apply]")
+	return 2
+}
+
+// rebuildProjections regenera delivery_projection a partir de deliveryEvents.
+// Sin parámetros reconstruye todos los streams, con -deliveryId solo ese delivery.
+// Si algún stream falla no se reemplaza la proyección, salvo con -force.
+func rebuildProjections(args []string) int {
+	flags := flag.NewFlagSet("rebuild-projections", flag.ContinueOnError)
+	deliveryId := flags.String("deliveryId", "", "Reconstruir solo el delivery indicado")
+	force := flags.Bool("force", false, "Reemplazar la proyección aunque haya streams con error")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	logger := log.Get().
+		WithField(log.LOG_FIELD_CONTROLLER, "Cli").
+		WithField(log.LOG_FIELD_CORRELATION_ID, "rebuild-projections")
+
+	var result *projections.RebuildResult
+	var err error
+	if len(*deliveryId) > 0 {
+		result, err = projections.RebuildDelivery(*deliveryId, logger)
+	} else {
+		result, err = projections.Rebuild(*force, logger)
+	}
+	if result != nil {
+		logger.Info("Streams procesados: ", result.Processed, ", aplicados: ", result.Applied, ", reaplicados: ", result.CaughtUp)
+		for id, reason := range result.Failed {
+			logger.Error("Stream con error ", id, ": ", reason)
+		}
+	}
+	if err != nil {
+		logger.Error(err)
+		return 1
+	}
+	if len(result.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// dedupeDeliveries informa los deliveries duplicados por orden o por mensaje, que impiden crear
+// los índices únicos de deliveryEvents. Con -apply conserva el más antiguo de cada grupo, el mismo
+// que ya devuelven las búsquedas por orden, y archiva los demás en deliveryEventsDuplicates.
+// Sin -apply termina con código 1 si hay duplicados.
+func dedupeDeliveries(args []string) int {
+	flags := flag.NewFlagSet("dedupe-deliveries", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "Archivar los deliveries duplicados")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	logger := log.Get().
+		WithField(log.LOG_FIELD_CONTROLLER, "Cli").
+		WithField(log.LOG_FIELD_CORRELATION_ID, "dedupe-deliveries")
+
+	duplicates, err := events.FindDuplicateDeliveries(logger)
+	if err != nil {
+		logger.Error(err)
+		return 1
+	}
+
+	out, _ := json.MarshalIndent(duplicates, "", "  ")
+	fmt.Println(string(out))
+	if len(duplicates) == 0 {
+		logger.Info("No hay deliveries duplicados")
+		return 0
+	}
+	if !*apply {
+		logger.Info("Grupos con deliveries duplicados: ", len(duplicates), ", usar -apply para archivar todos menos el más antiguo de cada grupo")
+		return 1
+	}
+
+	// Un delivery puede estar en un grupo por orden y en otro por mensaje
+	archived := map[string]bool{}
+	for _, group := range duplicates {
+		kept := false
+		for _, d := range group.Deliveries {
+			if archived[d.DeliveryId] {
+				continue
+			}
+			if !kept {
+				kept = true
+				continue
+			}
+
+			moved, err := events.ArchiveDeliveryStream(d.DeliveryId, logger)
+			if err != nil {
+				logger.Error("No se pudo archivar el delivery ", d.DeliveryId, ": ", err)
+				return 1
+			}
+			archived[d.DeliveryId] = true
+			logger.Info("Delivery ", d.DeliveryId, " archivado (", group.Field, " ", group.Value, "), eventos: ", moved)
+		}
+	}
+
+	logger.Info("Deliveries archivados: ", len(archived), ", regenerar la proyección con deliverygo rebuild-projections")
+	return 0
+}
+
+// deadLetters administra la DLQ de una cola:
+//
+//	dlq list [-limit <n>]                lista los mensajes sin sacarlos de la DLQ
+//	dlq requeue -id <messageId> | -all   devuelve mensajes a la cola original
+//	dlq discard -id <messageId> -reason  descarta un mensaje
+//	dlq audit [-limit <n>]               lista las acciones registradas
+//
+// Las acciones quedan en la auditoría a nombre de cli:<usuario>.
+func deadLetters(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Uso: deliverygo dlq <list|requeue|discard|audit> [opciones]")
+		return 2
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("dlq "+action, flag.ContinueOnError)
+	queue := flags.String("queue", consume.CreateDeliveryQueue, "Cola original: "+strings.Join(deadletter.Queues(), ", "))
+	messageId := flags.String("id", "", "MessageId del mensaje")
+	all := flags.Bool("all", false, "Devolver todos los mensajes a la cola original")
+	reason := flags.String("reason", "", "Motivo del descarte")
+	limit := flags.Int("limit", 50, "Cantidad máxima de resultados")
+	user := flags.String("user", os.Getenv("USER"), "Usuario que ejecuta la acción")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	logger := log.Get().
+		WithField(log.LOG_FIELD_CONTROLLER, "Cli").
+		WithField(log.LOG_FIELD_CORRELATION_ID, "dlq-"+action)
+	userId := "cli:" + *user
+
+	var result interface{}
+	var err error
+	switch {
+	case action == "list":
+		result, err = deadletter.List(*queue, *limit, logger)
+	case action == "requeue" && *all:
+		result, err = deadletter.RequeueAll(*queue, userId, logger)
+	case action == "requeue" && len(*messageId) > 0:
+		result, err = deadletter.Requeue(*queue, *messageId, userId, logger)
+	case action == "discard" && len(*messageId) > 0:
+		result, err = deadletter.Discard(*queue, *messageId, *reason, userId, logger)
+	case action == "audit":
+		result, err = deadletter.FindAudit(*queue, int64(*limit), logger)
+	default:
+		fmt.Println("Uso: deliverygo dlq <list|requeue|discard|audit> [opciones]")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	if err != nil {
+		logger.Error(err)
+		// RequeueAll retorna los mensajes que se llegaron a devolver antes del error
+		if requeued, ok := result.([]*deadletter.Message); ok && len(requeued) > 0 {
+			logger.Info("Mensajes devueltos antes del error: ", len(requeued))
+		}
+		return 1
+	}
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	return 0
+}
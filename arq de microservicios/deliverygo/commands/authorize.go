@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"deliverygo/couriers"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+)
+
+// canExecute retorna true si el usuario tiene el permiso que pide la transición.
+// Las transiciones sin permiso especial las puede ejecutar el dueño del delivery, las de courier
+// solo el courier asignado mientras esté habilitado, el admin siempre puede.
+func canExecute(transition *events.Transition, user *security.User, ownerId, courierId string, deps ...interface{}) (bool, error) {
+	if !hasPermission(transition, user, ownerId, courierId) {
+		return false, nil
+	}
+	if transition.Permission == events.PermissionAssignedCourier && !user.HasPermission(security.PermissionAdmin) {
+		return courierEnabled(user.ID, deps...)
+	}
+	return true, nil
+}
+
+// hasPermission valida el permiso de la transición sin consultar el estado del courier
+func hasPermission(transition *events.Transition, user *security.User, ownerId, courierId string) bool {
+	if user.HasPermission(security.PermissionAdmin) {
+		return true
+	}
+
+	switch transition.Permission {
+	case events.PermissionOwner:
+		return user.ID == ownerId
+	case events.PermissionAssignedCourier:
+		return courierId != "" && user.ID == courierId
+	}
+
+	return user.HasPermission(transition.Permission)
+}
+
+// courierEnabled retorna true si el usuario está dado de alta como courier y habilitado
+func courierEnabled(userId string, deps ...interface{}) (bool, error) {
+	courier, err := couriers.FindByUserId(userId, deps...)
+	if err == errs.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return courier.Enabled, nil
+}
+
+// checkAccepted retorna ErrAssignmentNotAccepted si la transición requiere que el courier asignado
+// haya aceptado el delivery y todavía no lo hizo. El admin puede ejecutarla igual.
+func checkAccepted(delivery *events.Delivery, transition *events.Transition, user *security.User) error {
+	if !delivery.CanApply(transition) && !user.HasPermission(security.PermissionAdmin) {
+		return events.ErrAssignmentNotAccepted
+	}
+	return nil
+}
+
+// AllowedTransitions retorna las transiciones que el usuario puede ejecutar sobre el delivery en su estado actual.
+// Las que requieren la aceptación del courier solo se incluyen si ya aceptó, salvo para el admin.
+// No consulta si el courier está habilitado, eso se valida al ejecutar la transición.
+func AllowedTransitions(delivery *projections.DeliveryProjection, user *security.User) []events.Transition {
+	admin := user.HasPermission(security.PermissionAdmin)
+	result := []events.Transition{}
+	for _, t := range events.AvailableTransitions(events.DeliveryStatus(delivery.Status)) {
+		if t.Accepted && !admin && (delivery.CourierId == "" || !delivery.CourierAccepted) {
+			continue
+		}
+		if hasPermission(&t, user, delivery.UserId, delivery.CourierId) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"testing"
+
+	"deliverygo/events"
+	"deliverygo/security"
+)
+
+func TestCheckAccepted(t *testing.T) {
+	courier := &security.User{ID: "c1", Permissions: []string{security.PermissionCourier}}
+	admin := &security.User{ID: "a1", Permissions: []string{security.PermissionAdmin}}
+
+	tests := []struct {
+		name     string
+		delivery events.Delivery
+		event    events.EventType
+		user     *security.User
+		err      error
+	}{
+		{"sin courier", events.Delivery{Status: events.DeliveryStatusConfirmed}, events.SetOnTheGoDelivery, courier, events.ErrAssignmentNotAccepted},
+		{"asignado sin aceptar", events.Delivery{Status: events.DeliveryStatusConfirmed, CourierId: "c1"}, events.SetOnTheGoDelivery, courier, events.ErrAssignmentNotAccepted},
+		{"aceptado", events.Delivery{Status: events.DeliveryStatusConfirmed, CourierId: "c1", Accepted: true}, events.SetOnTheGoDelivery, courier, nil},
+		{"admin sin aceptar", events.Delivery{Status: events.DeliveryStatusConfirmed, CourierId: "c1"}, events.SetOnTheGoDelivery, admin, nil},
+		{"transición sin aceptación", events.Delivery{Status: events.DeliveryStatusOnTheGo, CourierId: "c1"}, events.SetDeliveredDelivery, courier, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition, err := events.FindTransition(tt.delivery.Status, tt.event)
+			if err != nil {
+				t.Fatalf("FindTransition: %v", err)
+			}
+			if err := checkAccepted(&tt.delivery, transition, tt.user); err != tt.err {
+				t.Errorf("err = %v, se esperaba %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+)
+
+// MaxBulkDeliveries es la cantidad máxima de deliveries que se pueden cambiar en una sola operación
+const MaxBulkDeliveries = 200
+
+// BulkOutcome resultado del cambio de estado de un delivery dentro de una operación masiva
+type BulkOutcome string
+
+const (
+	BulkOutcomeOk        BulkOutcome = "ok"
+	BulkOutcomeConflict  BulkOutcome = "conflict"  // La transición no es válida, falta la aceptación del courier o hubo un cambio concurrente
+	BulkOutcomeNotFound  BulkOutcome = "not_found" // El delivery no existe
+	BulkOutcomeForbidden BulkOutcome = "forbidden" // El usuario no puede ejecutar la transición
+	BulkOutcomeError     BulkOutcome = "error"     // Error inesperado
+)
+
+// BulkResult es el resultado de un delivery
+type BulkResult struct {
+	DeliveryId string
+	Outcome    BulkOutcome
+	Delivery   *projections.DeliveryProjection // Estado después del cambio, solo si Outcome es ok
+	Current    events.DeliveryStatus           // Estado actual, si la transición no era válida
+	Err        error
+}
+
+// BulkChangeStatus lleva cada delivery al estado pedido, de a uno y con las mismas validaciones
+// que ChangeStatus. Cada cambio se guarda como un evento normal, los que fallan no afectan al resto.
+// Los ids repetidos se procesan una sola vez.
+func BulkChangeStatus(deliveryIds []string, status events.DeliveryStatus, user *security.User, deps ...interface{}) ([]*BulkResult, error) {
+	v := errs.NewValidation()
+	if !status.IsValid() || status == events.DeliveryStatusConfirmed {
+		v.Add("status", fmt.Sprintf("invalid status: %s", status))
+	}
+	if len(deliveryIds) == 0 {
+		v.Add("deliveryIds", "Se requiere al menos un delivery")
+	}
+	if len(deliveryIds) > MaxBulkDeliveries {
+		v.Add("deliveryIds", fmt.Sprintf("Se pueden cambiar hasta %d deliveries por operación", MaxBulkDeliveries))
+	}
+	if v.HasErrors() {
+		return nil, v
+	}
+
+	result := []*BulkResult{}
+	processed := map[string]bool{}
+	for _, deliveryId := range deliveryIds {
+		if processed[deliveryId] {
+			continue
+		}
+		processed[deliveryId] = true
+
+		delivery, err := ChangeStatus(deliveryId, status, user, deps...)
+		result = append(result, newBulkResult(deliveryId, delivery, err))
+	}
+
+	return result, nil
+}
+
+// newBulkResult clasifica el resultado de un cambio
+func newBulkResult(deliveryId string, delivery *projections.DeliveryProjection, err error) *BulkResult {
+	result := &BulkResult{DeliveryId: deliveryId, Delivery: delivery, Err: err}
+
+	switch e := err.(type) {
+	case nil:
+		result.Outcome = BulkOutcomeOk
+	case *events.InvalidTransitionError:
+		result.Outcome = BulkOutcomeConflict
+		result.Current = e.Current
+	default:
+		switch err {
+		case events.ErrVersionConflict, events.ErrAssignmentNotAccepted:
+			result.Outcome = BulkOutcomeConflict
+		case errs.NotFound:
+			result.Outcome = BulkOutcomeNotFound
+		case errs.Forbidden:
+			result.Outcome = BulkOutcomeForbidden
+		default:
+			result.Outcome = BulkOutcomeError
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+)
+
+// ChangeAddress reemplaza la dirección de entrega, solo mientras el delivery está confirmado
+func ChangeAddress(deliveryId string, address *events.Address, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	return withRetry(deliveryId, func() (*projections.DeliveryProjection, error) {
+		delivery, err := loadDelivery(deliveryId, deps...)
+		if err != nil {
+			return nil, err
+		}
+
+		transition, err := events.FindTransition(delivery.Status, events.AddressChanged)
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := canExecute(transition, user, delivery.UserId, delivery.CourierId, deps...)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errs.Forbidden
+		}
+
+		event, err := delivery.NewAddressChangedEvent(user.ID, address)
+		if err != nil {
+			return nil, err
+		}
+
+		return appendEvent(event, deps...)
+	}, deps...)
+}
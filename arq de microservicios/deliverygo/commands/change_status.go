@@ -0,0 +1,103 @@
+// Comandos sobre los deliveries. Cada comando genera el evento correspondiente,
+// lo guarda en el event store y luego actualiza la proyección.
+package commands
+
+import (
+	"fmt"
+
+	"deliverygo/broadcast"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+)
+
+// maxAppendAttempts es la cantidad de veces que se reintenta un comando cuando otro
+// evento se guardó en el stream entre la lectura y el insert
+const maxAppendAttempts = 3
+
+// ChangeStatus cambia el estado de un delivery, validando la transición contra el estado actual
+// y los permisos del usuario. Si la transición no es válida retorna un *events.InvalidTransitionError.
+// Si otro cambio se guardó en simultáneo se vuelve a evaluar contra el nuevo estado, y si no se
+// logra guardar después de maxAppendAttempts intentos retorna events.ErrVersionConflict.
+func ChangeStatus(deliveryId string, status events.DeliveryStatus, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	return withRetry(deliveryId, func() (*projections.DeliveryProjection, error) {
+		return changeStatus(deliveryId, status, user, deps...)
+	}, deps...)
+}
+
+func changeStatus(deliveryId string, status events.DeliveryStatus, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	if !status.IsValid() || status == events.DeliveryStatusConfirmed {
+		return nil, errs.NewValidation().Add("status", fmt.Sprintf("invalid status: %s", status))
+	}
+
+	delivery, err := loadDelivery(deliveryId, deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	transition, err := events.FindTransitionTo(delivery.Status, status)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := canExecute(transition, user, delivery.UserId, delivery.CourierId, deps...)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errs.Forbidden
+	}
+	if err := checkAccepted(delivery, transition, user); err != nil {
+		return nil, err
+	}
+
+	event, err := delivery.NewStatusChangeEvent(user.ID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendEvent(event, deps...)
+}
+
+// withRetry ejecuta el comando y lo reintenta si otro evento ocupó la versión en el stream.
+// Si se indicó ExpectedVersion en deps no se reintenta y se retorna ErrPreconditionFailed.
+func withRetry(deliveryId string, command func() (*projections.DeliveryProjection, error), deps ...interface{}) (*projections.DeliveryProjection, error) {
+	for attempt := 1; ; attempt++ {
+		delivery, err := command()
+		if err == events.ErrVersionConflict {
+			// Con versión esperada no se reintenta, el delivery ya no está como lo leyó el cliente
+			if _, ok := expectedVersion(deps...); ok {
+				return nil, ErrPreconditionFailed
+			}
+		}
+		if err != events.ErrVersionConflict || attempt >= maxAppendAttempts {
+			return delivery, err
+		}
+		log.Get(deps...).Info("Conflicto de versión, reintentando comando: ", deliveryId)
+	}
+}
+
+// appendEvent guarda el evento en el stream, actualiza la proyección del delivery y lo notifica
+// a los streams conectados. La publicación en RabbitMQ la hace el relay del outbox.
+func appendEvent(event *events.Event, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	if service, ok := serviceCaller(deps...); ok {
+		event.ServiceId = service
+	}
+	if _, err := events.InsertDeliveryEvent(event, deps...); err != nil {
+		return nil, err
+	}
+
+	// El evento ya está guardado, si la proyección no se pudo actualizar se regenera desde el stream
+	// en lugar de fallar un comando que se aplicó
+	if err := projections.Update(event.DeliveryId, []*events.Event{event}, deps...); err != nil {
+		log.Get(deps...).Error(err)
+		if _, err := projections.RebuildDelivery(event.DeliveryId, deps...); err != nil {
+			log.Get(deps...).Error(err)
+		}
+	}
+
+	broadcast.PublishEvent(event)
+
+	return projections.FindByDeliveryId(event.DeliveryId, deps...)
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"deliverygo/couriers"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+)
+
+// AssignCourier asigna un courier habilitado al delivery
+func AssignCourier(deliveryId, courierId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	courier, err := couriers.FindByUserId(courierId, deps...)
+	if err == errs.NotFound || (err == nil && !courier.Enabled) {
+		return nil, errs.NewValidation().Add("courierId", "Courier inexistente o deshabilitado")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return withRetry(deliveryId, func() (*projections.DeliveryProjection, error) {
+		delivery, err := loadDelivery(deliveryId, deps...)
+		if err != nil {
+			return nil, err
+		}
+
+		transition, err := events.FindTransition(delivery.Status, events.AssignCourier)
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := canExecute(transition, user, delivery.UserId, delivery.CourierId, deps...)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errs.Forbidden
+		}
+
+		event, err := delivery.NewAssignCourierEvent(user.ID, courier.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		return appendEvent(event, deps...)
+	}, deps...)
+}
+
+// UnassignCourier quita el courier asignado al delivery
+func UnassignCourier(deliveryId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	return withRetry(deliveryId, func() (*projections.DeliveryProjection, error) {
+		delivery, err := loadDelivery(deliveryId, deps...)
+		if err != nil {
+			return nil, err
+		}
+
+		transition, err := events.FindTransition(delivery.Status, events.UnassignCourier)
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := canExecute(transition, user, delivery.UserId, delivery.CourierId, deps...)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errs.Forbidden
+		}
+
+		event, err := delivery.NewUnassignCourierEvent(user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return appendEvent(event, deps...)
+	}, deps...)
+}
+
+// AcceptAssignment el courier asignado acepta llevar el delivery
+func AcceptAssignment(deliveryId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	return withRetry(deliveryId, func() (*projections.DeliveryProjection, error) {
+		delivery, err := loadDelivery(deliveryId, deps...)
+		if err != nil {
+			return nil, err
+		}
+
+		transition, err := events.FindTransition(delivery.Status, events.AcceptAssignment)
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := canExecute(transition, user, delivery.UserId, delivery.CourierId, deps...)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errs.Forbidden
+		}
+
+		event, err := delivery.NewAcceptAssignmentEvent(user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return appendEvent(event, deps...)
+	}, deps...)
+}
+
+// RejectAssignment el courier asignado rechaza el delivery, se registra como UnassignCourier
+func RejectAssignment(deliveryId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	return UnassignCourier(deliveryId, user, deps...)
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrCorrelationIdConflict el correlationId del mensaje ya se usó para crear el delivery de otra orden
+var ErrCorrelationIdConflict = errs.NewRestError(409, "Correlation id already used for another order")
+
+// CreateDelivery registra un delivery nuevo, confirmado, para la orden del usuario.
+// Si ya existe un delivery para la orden o creado por el mismo mensaje (correlationId) para la
+// misma orden no crea otro, retorna el existente con created en false.
+func CreateDelivery(orderId, userId string, address *events.Address, correlationId string, deps ...interface{}) (*projections.DeliveryProjection, bool, error) {
+	deliveryId, err := findExistingDelivery(orderId, correlationId, deps...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(deliveryId) == 0 {
+		event := events.NewConfirmDeliveryEvent(primitive.NewObjectID().Hex(), orderId, userId, address, correlationId)
+		delivery, err := appendEvent(event, deps...)
+		if err != events.ErrDuplicateDelivery {
+			return delivery, err == nil, err
+		}
+
+		// Otro proceso creó el delivery al mismo tiempo
+		if deliveryId, err = findExistingDelivery(orderId, correlationId, deps...); err != nil {
+			return nil, false, err
+		}
+		if len(deliveryId) == 0 {
+			return nil, false, events.ErrDuplicateDelivery
+		}
+	}
+
+	delivery, err := existingProjection(deliveryId, deps...)
+	return delivery, false, err
+}
+
+// findExistingDelivery busca un delivery de la orden o creado con el correlationId, "" si no hay.
+// Si el correlationId ya creó el delivery de otra orden retorna ErrCorrelationIdConflict.
+func findExistingDelivery(orderId, correlationId string, deps ...interface{}) (string, error) {
+	deliveryId, err := events.FindDeliveryIdByOrderId(orderId, deps...)
+	if err == errs.NotFound && len(correlationId) > 0 {
+		var event *events.Event
+		if event, err = events.FindConfirmEventByCorrelationId(correlationId, deps...); err == nil {
+			if event.OrderId != orderId {
+				log.Get(deps...).Error("correlationId ", correlationId, " ya creó el delivery de la orden ", event.OrderId, ", recibido para ", orderId)
+				return "", ErrCorrelationIdConflict
+			}
+			deliveryId = event.DeliveryId
+		}
+	}
+	if err == errs.NotFound {
+		return "", nil
+	}
+	return deliveryId, err
+}
+
+// existingProjection retorna la proyección de un delivery ya creado. Si un intento anterior guardó
+// el evento pero falló al actualizar la proyección, la regenera desde el stream.
+func existingProjection(deliveryId string, deps ...interface{}) (*projections.DeliveryProjection, error) {
+	delivery, err := projections.FindByDeliveryId(deliveryId, deps...)
+	if err != mongo.ErrNoDocuments {
+		return delivery, err
+	}
+
+	if _, err := projections.RebuildDelivery(deliveryId, deps...); err != nil {
+		return nil, err
+	}
+	return projections.FindByDeliveryId(deliveryId, deps...)
+}
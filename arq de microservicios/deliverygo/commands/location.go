@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"time"
+
+	"deliverygo/broadcast"
+	"deliverygo/events"
+	"deliverygo/locations"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotOnTheGo se produce al registrar una posición de un delivery que no está en camino
+var ErrNotOnTheGo = errs.NewRestError(409, "Delivery is not on_the_go")
+
+// RecordLocation guarda una posición del courier asignado mientras el delivery está en camino.
+// Las posiciones no son eventos del delivery, se valida contra la proyección para no leer el stream en cada ping.
+func RecordLocation(deliveryId string, lat, lng, accuracy float64, timestamp time.Time, user *security.User, deps ...interface{}) (*locations.Ping, error) {
+	delivery, err := projections.FindByDeliveryId(deliveryId, deps...)
+	if err == mongo.ErrNoDocuments {
+		return nil, errs.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if delivery.CourierId == "" || delivery.CourierId != user.ID {
+		return nil, errs.Forbidden
+	}
+	enabled, err := courierEnabled(user.ID, deps...)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, errs.Forbidden
+	}
+	if delivery.Status != string(events.DeliveryStatusOnTheGo) {
+		return nil, ErrNotOnTheGo
+	}
+
+	ping, err := locations.NewPing(deliveryId, user.ID, lat, lng, accuracy, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := locations.Insert(ping, deps...); err != nil {
+		return nil, err
+	}
+
+	broadcast.PublishLocation(ping)
+	return ping, nil
+}
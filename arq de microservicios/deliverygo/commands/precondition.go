@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"deliverygo/events"
+	"deliverygo/tools/errs"
+)
+
+// ErrPreconditionFailed el delivery cambió desde la versión que indicó el cliente
+var ErrPreconditionFailed = errs.NewRestError(412, "Delivery was modified since it was read")
+
+// ExpectedVersion se agrega a deps para que el comando solo se aplique si el delivery
+// sigue en esa versión del stream (If-Match)
+type ExpectedVersion int64
+
+// expectedVersion busca en deps la versión esperada
+func expectedVersion(deps ...interface{}) (int64, bool) {
+	for _, o := range deps {
+		if v, ok := o.(ExpectedVersion); ok {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// ServiceCaller se agrega a deps cuando un servicio ejecuta el comando en nombre de un usuario,
+// el evento queda a nombre del usuario y registra el servicio
+type ServiceCaller string
+
+// serviceCaller busca en deps el servicio que ejecuta el comando
+func serviceCaller(deps ...interface{}) (string, bool) {
+	for _, o := range deps {
+		if v, ok := o.(ServiceCaller); ok {
+			return string(v), true
+		}
+	}
+	return "", false
+}
+
+// loadDelivery reconstruye el delivery y verifica que esté en la versión esperada, si se indicó una.
+// El evento que genera el comando ocupa la versión siguiente, así que si otro cambio se guarda
+// antes el insert falla y withRetry lo informa como ErrPreconditionFailed.
+func loadDelivery(deliveryId string, deps ...interface{}) (*events.Delivery, error) {
+	delivery, err := events.LoadDelivery(deliveryId, deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	if version, ok := expectedVersion(deps...); ok && version != delivery.Version {
+		return nil, ErrPreconditionFailed
+	}
+	return delivery, nil
+}
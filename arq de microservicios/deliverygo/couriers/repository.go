@@ -0,0 +1,152 @@
+package couriers
+
+import (
+	"context"
+	"time"
+
+	"deliverygo/tools/db"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var collection *mongo.Collection
+
+// Configura y devuelve la colección couriers de MongoDB.
+func dbCollection(deps ...interface{}) (*mongo.Collection, error) {
+	if collection != nil {
+		return collection, nil
+	}
+
+	database, err := db.Get(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	col := database.Collection("couriers")
+
+	_, err = col.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.M{"userId": 1}, // Un courier por usuario
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	collection = col
+	return collection, nil
+}
+
+// Insert guarda un courier nuevo
+func Insert(courier *Courier, deps ...interface{}) (*Courier, error) {
+	courier.Created = time.Now()
+	courier.Updated = time.Now()
+	if err := courier.Validate(); err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := col.InsertOne(context.Background(), courier)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		if db.IsUniqueKeyError(err) {
+			return nil, errs.AlreadyExist
+		}
+		return nil, err
+	}
+
+	return FindById(result.InsertedID, deps...)
+}
+
+// SetEnabled habilita o deshabilita un courier
+func SetEnabled(userId string, enabled bool, deps ...interface{}) (*Courier, error) {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := col.UpdateOne(context.Background(), bson.M{"userId": userId}, bson.M{
+		"$set": bson.M{
+			"enabled": enabled,
+			"updated": time.Now(),
+		},
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, errs.NotFound
+	}
+
+	return FindByUserId(userId, deps...)
+}
+
+// FindById busca un courier por el id de mongo
+func FindById(id interface{}, deps ...interface{}) (*Courier, error) {
+	return findOne(bson.M{"_id": id}, deps...)
+}
+
+// FindByUserId busca un courier por el userId
+func FindByUserId(userId string, deps ...interface{}) (*Courier, error) {
+	return findOne(bson.M{"userId": userId}, deps...)
+}
+
+// FindAll retorna todos los couriers
+func FindAll(deps ...interface{}) ([]*Courier, error) {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cur, err := col.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := []*Courier{}
+	for cur.Next(context.Background()) {
+		courier := &Courier{}
+		if err := cur.Decode(courier); err != nil {
+			log.Get(deps...).Error(err)
+			return nil, err
+		}
+		result = append(result, courier)
+	}
+
+	return result, nil
+}
+
+func findOne(filter bson.M, deps ...interface{}) (*Courier, error) {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	courier := &Courier{}
+	if err := col.FindOne(context.Background(), filter).Decode(courier); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errs.NotFound
+		}
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	return courier, nil
+}
@@ -0,0 +1,26 @@
+// Define los couriers, los usuarios que llevan los deliveries.
+// Un courier se identifica por el userId que tiene en el servicio de autenticación.
+package couriers
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Courier representa a un repartidor
+type Courier struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserId  string             `bson:"userId" json:"userId" validate:"required"` // ID del usuario del courier
+	Name    string             `bson:"name" json:"name" validate:"required,min=1,max=100"`
+	Phone   string             `bson:"phone" json:"phone" validate:"max=30"`
+	Enabled bool               `bson:"enabled" json:"enabled"` // Solo se asignan couriers habilitados
+	Created time.Time          `bson:"created" json:"created"`
+	Updated time.Time          `bson:"updated" json:"updated"`
+}
+
+// Validate valida la estructura
+func (c *Courier) Validate() error {
+	return validator.New().Struct(c)
+}
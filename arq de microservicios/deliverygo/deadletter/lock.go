@@ -0,0 +1,86 @@
+package deadletter
+
+import (
+	"context"
+	"time"
+
+	"deliverygo/tools/db"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrQueueBusy otra operación, de esta u otra instancia, está usando la DLQ
+var ErrQueueBusy = errs.NewRestError(409, "La DLQ está siendo usada por otra operación, reintentar")
+
+// Duración máxima de una operación sobre la DLQ, si la instancia se cae el bloqueo se libera al vencer
+const lockLease = 15 * time.Minute
+
+// queueLock bloquea una DLQ mientras se opera sobre ella. Los mensajes leídos quedan sin confirmar
+// y otra operación vería la cola incompleta, por eso se permite una sola a la vez.
+type queueLock struct {
+	Queue string    `bson:"_id"`
+	Owner string    `bson:"owner"`
+	Until time.Time `bson:"until"`
+}
+
+var lockCollection *mongo.Collection
+
+// Configura y devuelve la colección dlqLocks de MongoDB.
+func dbLockCollection(deps ...interface{}) (*mongo.Collection, error) {
+	if lockCollection != nil {
+		return lockCollection, nil
+	}
+
+	database, err := db.Get(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	lockCollection = database.Collection("dlqLocks")
+	return lockCollection, nil
+}
+
+// lock toma el bloqueo de la DLQ de la cola. Si otra operación lo tiene retorna ErrQueueBusy.
+func lock(queue string, deps ...interface{}) (*queueLock, error) {
+	col, err := dbLockCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &queueLock{Queue: queue, Owner: uuid.NewV4().String(), Until: now.Add(lockLease)}
+
+	// Si el bloqueo existe y no venció el filtro no coincide y el upsert choca con el _id
+	_, err = col.ReplaceOne(
+		context.Background(),
+		bson.M{"_id": queue, "until": bson.M{"$lt": now}},
+		result,
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrQueueBusy
+	}
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// unlock libera el bloqueo si sigue siendo de esta operación
+func (l *queueLock) unlock(deps ...interface{}) {
+	col, err := dbLockCollection(deps...)
+	if err != nil {
+		return
+	}
+
+	if _, err := col.DeleteOne(context.Background(), bson.M{"_id": l.Queue, "owner": l.Owner}); err != nil {
+		log.Get(deps...).Error(err)
+	}
+}
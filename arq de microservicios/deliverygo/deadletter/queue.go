@@ -0,0 +1,343 @@
+package deadletter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"deliverygo/rabbit"
+	"deliverygo/rabbit/consume"
+	"deliverygo/tools/env"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"github.com/streadway/amqp"
+)
+
+// queues son las colas que tienen DLQ, por nombre de la cola original
+var queues = map[string]string{
+	consume.CreateDeliveryQueue: consume.CreateDeliveryDLQ,
+}
+
+// ErrUnknownQueue la cola no existe o no tiene DLQ
+var ErrUnknownQueue = errs.NewRestError(404, "La cola no tiene DLQ")
+
+const (
+	requeueConfirmWait = 10 * time.Second // Espera máxima del confirm de RabbitMQ al devolver un mensaje a su cola
+	maxListMessages    = 500              // Mensajes que lista List como máximo
+	maxFindScan        = 1000             // Mensajes que se leen buscando un messageId antes de responder NotFound
+)
+
+// Queues retorna los nombres de las colas que tienen DLQ
+func Queues() []string {
+	result := []string{}
+	for queue := range queues {
+		result = append(result, queue)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// List retorna hasta limit mensajes de la DLQ de la cola, como máximo maxListMessages, sin sacarlos de la DLQ
+func List(queue string, limit int, deps ...interface{}) ([]*Message, error) {
+	if limit <= 0 || limit > maxListMessages {
+		limit = maxListMessages
+	}
+
+	s, err := openSession(queue, deps...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close(deps...)
+
+	result := []*Message{}
+	for len(result) < limit {
+		d, err := s.get()
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			break
+		}
+		result = append(result, newMessage(d, queue))
+	}
+
+	return result, nil
+}
+
+// Requeue devuelve un mensaje de la DLQ a su cola original, con los intentos en cero
+func Requeue(queue, messageId, userId string, deps ...interface{}) (*Message, error) {
+	s, err := openSession(queue, deps...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close(deps...)
+
+	d, err := s.find(messageId)
+	if err != nil {
+		return nil, err
+	}
+	return s.requeue(d, userId, deps...)
+}
+
+// RequeueAll devuelve a la cola original los mensajes que estaban en la DLQ al empezar.
+// Los que vuelvan a fallar mientras tanto quedan en la DLQ. Si hay un error retorna los
+// mensajes que se llegaron a devolver.
+func RequeueAll(queue, userId string, deps ...interface{}) ([]*Message, error) {
+	s, err := openSession(queue, deps...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close(deps...)
+
+	result := []*Message{}
+	pending := 1
+	for i := 0; i < pending; i++ {
+		d, err := s.get()
+		if err != nil {
+			return result, err
+		}
+		if d == nil {
+			break
+		}
+		if i == 0 {
+			// MessageCount son los mensajes que quedan en la DLQ después del leído
+			pending += int(d.MessageCount)
+		}
+
+		message, err := s.requeue(d, userId, deps...)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, message)
+	}
+
+	return result, nil
+}
+
+// Discard borra un mensaje de la DLQ, el contenido queda guardado en la auditoría
+func Discard(queue, messageId, reason, userId string, deps ...interface{}) (*Message, error) {
+	if len(reason) == 0 {
+		return nil, errs.NewValidation().Add("reason", "Se requiere el motivo para descartar un mensaje")
+	}
+
+	s, err := openSession(queue, deps...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close(deps...)
+
+	d, err := s.find(messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	message := newMessage(d, queue)
+	audit := newAuditRecord(ActionDiscard, message, reason, userId)
+	if err := insertAudit(audit, deps...); err != nil {
+		return nil, err
+	}
+	if err := d.Ack(false); err != nil {
+		finishAudit(audit, err, deps...)
+		return nil, err
+	}
+	finishAudit(audit, nil, deps...)
+
+	return message, nil
+}
+
+// session es una conexión propia para operar sobre una DLQ. Los mensajes leídos no se
+// confirman hasta terminar la acción, los que no se confirmaron vuelven a la DLQ al cerrar.
+// Mientras está abierta tiene el bloqueo de la DLQ, así otra operación no ve la cola incompleta.
+type session struct {
+	conn        *amqp.Connection
+	channel     *amqp.Channel
+	confirms    chan amqp.Confirmation
+	deliveryTag uint64 // Número del último mensaje publicado en el canal
+	queue       string
+	dlq         string
+	lock        *queueLock
+}
+
+func openSession(queue string, deps ...interface{}) (*session, error) {
+	dlq, ok := queues[queue]
+	if !ok {
+		return nil, ErrUnknownQueue
+	}
+
+	l, err := lock(queue, deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := amqp.Dial(env.Get().RabbitURL)
+	if err != nil {
+		log.Get(deps...).Error("Error al conectar con RabbitMQ: ", err)
+		l.unlock(deps...)
+		return nil, err
+	}
+
+	chn, err := conn.Channel()
+	if err != nil {
+		log.Get(deps...).Error("Error al crear el canal: ", err)
+		conn.Close()
+		l.unlock(deps...)
+		return nil, err
+	}
+
+	if err := chn.Confirm(false); err != nil {
+		log.Get(deps...).Error("Error al activar confirms: ", err)
+		conn.Close()
+		l.unlock(deps...)
+		return nil, err
+	}
+
+	return &session{
+		conn:     conn,
+		channel:  chn,
+		confirms: chn.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		queue:    queue,
+		dlq:      dlq,
+		lock:     l,
+	}, nil
+}
+
+// close cierra la conexión, los mensajes sin confirmar vuelven a la DLQ, y libera el bloqueo
+func (s *session) close(deps ...interface{}) {
+	s.channel.Close()
+	s.conn.Close()
+	s.lock.unlock(deps...)
+}
+
+// get lee el próximo mensaje sin confirmarlo, nil si no quedan mensajes
+func (s *session) get() (*amqp.Delivery, error) {
+	d, ok, err := s.channel.Get(s.dlq, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// find lee mensajes hasta encontrar messageId, como máximo maxFindScan. Los mensajes que no
+// coinciden vuelven a la DLQ apenas termina la búsqueda, sin esperar a que termine la acción.
+func (s *session) find(messageId string) (*amqp.Delivery, error) {
+	var skipped uint64 // Último mensaje leído que no coincide
+	defer func() {
+		if skipped > 0 {
+			s.channel.Nack(skipped, true, true)
+		}
+	}()
+
+	for i := 0; i < maxFindScan; i++ {
+		d, err := s.get()
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			return nil, errs.NotFound
+		}
+		if deliveryMessageId(d) == messageId {
+			return d, nil
+		}
+		skipped = d.DeliveryTag
+	}
+	return nil, errs.NotFound
+}
+
+// requeue registra la auditoría como pendiente, publica el mensaje en la cola original, lo saca
+// de la DLQ y completa la auditoría. Si no se puede registrar la auditoría no se publica.
+func (s *session) requeue(d *amqp.Delivery, userId string, deps ...interface{}) (*Message, error) {
+	message := newMessage(d, s.queue)
+	audit := newAuditRecord(ActionRequeue, message, "", userId)
+	if err := insertAudit(audit, deps...); err != nil {
+		return nil, err
+	}
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	delete(headers, consume.HeaderAttempts)
+
+	err := s.channel.Publish(
+		"",      // Exchange por defecto, el routing key es el nombre de la cola
+		s.queue, // Routing key
+		false,   // Mandatory
+		false,   // Immediate
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			CorrelationId: d.CorrelationId,
+			MessageId:     message.MessageId,
+			Timestamp:     d.Timestamp,
+			Body:          d.Body,
+		},
+	)
+	if err == nil {
+		s.deliveryTag++
+		err = rabbit.WaitConfirm(s.confirms, s.deliveryTag, requeueConfirmWait)
+	}
+	if err != nil {
+		log.Get(deps...).Error("Error al publicar mensaje: ", err)
+		finishAudit(audit, err, deps...)
+		return nil, err
+	}
+
+	// Ya está en la cola original, si no se puede sacar de la DLQ queda en las dos
+	if err := d.Ack(false); err != nil {
+		finishAudit(audit, fmt.Errorf("publicado en %s pero sigue en la DLQ: %w", s.queue, err), deps...)
+		return nil, err
+	}
+	finishAudit(audit, nil, deps...)
+
+	return message, nil
+}
+
+func newMessage(d *amqp.Delivery, queue string) *Message {
+	result := &Message{
+		MessageId:     deliveryMessageId(d),
+		CorrelationId: d.CorrelationId,
+		Queue:         queue,
+		Attempts:      consume.MessageAttempts(d.Headers),
+		Headers:       map[string]interface{}(d.Headers),
+		ContentType:   d.ContentType,
+		Body:          string(d.Body),
+	}
+	if result.Headers == nil {
+		result.Headers = map[string]interface{}{}
+	}
+	if value, ok := d.Headers[consume.HeaderError].(string); ok {
+		result.Error = value
+	}
+	if value, ok := d.Headers[consume.HeaderFailedAt].(string); ok {
+		result.FailedAt = value
+	}
+	return result
+}
+
+func newAuditRecord(action Action, message *Message, reason, userId string) *AuditRecord {
+	return &AuditRecord{
+		Action:        action,
+		Queue:         message.Queue,
+		MessageId:     message.MessageId,
+		CorrelationId: message.CorrelationId,
+		Attempts:      message.Attempts,
+		Error:         message.Error,
+		Reason:        reason,
+		Body:          message.Body,
+		UserId:        userId,
+	}
+}
+
+// deliveryMessageId es el MessageId del mensaje. Los que llegaron a la DLQ sin MessageId
+// se identifican por el hash del contenido.
+func deliveryMessageId(d *amqp.Delivery) string {
+	if len(d.MessageId) > 0 {
+		return d.MessageId
+	}
+	hash := sha256.Sum256(d.Body)
+	return hex.EncodeToString(hash[:16])
+}
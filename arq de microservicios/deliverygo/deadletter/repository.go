@@ -0,0 +1,139 @@
+package deadletter
+
+import (
+	"context"
+	"time"
+
+	"deliverygo/tools/db"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var collection *mongo.Collection
+
+// Configura y devuelve la colección dlqAudit de MongoDB.
+func dbCollection(deps ...interface{}) (*mongo.Collection, error) {
+	if collection != nil {
+		return collection, nil
+	}
+
+	database, err := db.Get(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	col := database.Collection("dlqAudit")
+
+	_, err = col.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "queue", Value: 1}, {Key: "created", Value: -1}}, // Auditoría de una cola
+			},
+			{
+				Keys: bson.M{"messageId": 1}, // Acciones sobre un mensaje
+			},
+		},
+	)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	collection = col
+	return collection, nil
+}
+
+// insertAudit guarda el registro de una acción como pendiente, antes de ejecutarla
+func insertAudit(record *AuditRecord, deps ...interface{}) error {
+	record.ID = primitive.NewObjectID()
+	record.Status = AuditStatusPending
+	record.Created = time.Now()
+	if err := record.Validate(); err != nil {
+		return err
+	}
+
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := col.InsertOne(context.Background(), record); err != nil {
+		log.Get(deps...).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// finishAudit registra el resultado de la acción, cause nil si se ejecutó. La acción ya se
+// ejecutó o falló, si no se puede guardar el resultado queda pendiente y solo se informa en el log.
+func finishAudit(record *AuditRecord, cause error, deps ...interface{}) {
+	now := time.Now()
+	record.Finished = &now
+	record.Status = AuditStatusDone
+	if cause != nil {
+		record.Status = AuditStatusFailed
+		record.Failure = cause.Error()
+	}
+
+	logger := log.Get(deps...)
+	if cause != nil {
+		logger.Error("DLQ ", record.Queue, ": falló ", record.Action, " del mensaje ", record.MessageId, ": ", cause)
+	} else {
+		logger.Info("DLQ ", record.Queue, ": ", record.Action, " del mensaje ", record.MessageId, " por ", record.UserId)
+	}
+
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return
+	}
+
+	_, err = col.UpdateByID(context.Background(), record.ID, bson.M{
+		"$set": bson.M{
+			"status":   record.Status,
+			"failure":  record.Failure,
+			"finished": record.Finished,
+		},
+	})
+	if err != nil {
+		logger.Error("No se pudo guardar el resultado de la auditoría ", record.ID.Hex(), ": ", err)
+	}
+}
+
+// FindAudit busca las últimas acciones sobre la DLQ de una cola, de la más nueva a la más vieja
+func FindAudit(queue string, limit int64, deps ...interface{}) ([]*AuditRecord, error) {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"created": -1})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cur, err := col.Find(context.Background(), bson.M{"queue": queue}, opts)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := []*AuditRecord{}
+	for cur.Next(context.Background()) {
+		record := &AuditRecord{}
+		if err := cur.Decode(record); err != nil {
+			log.Get(deps...).Error(err)
+			return nil, err
+		}
+		result = append(result, record)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+// Administración de las dead letter queues de los mensajes consumidos.
+// Permite ver los mensajes que fallaron, devolverlos a su cola original o descartarlos,
+// dejando registro de cada acción en la colección dlqAudit.
+package deadletter
+
+import (
+	"time"
+
+	"deliverygo/tools/errs"
+
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Action acción ejecutada sobre un mensaje de la DLQ
+type Action string
+
+const (
+	ActionRequeue Action = "requeue" // Se devolvió a la cola original
+	ActionDiscard Action = "discard" // Se borró de la DLQ
+)
+
+// AuditStatus estado de la acción registrada
+type AuditStatus string
+
+const (
+	AuditStatusPending AuditStatus = "pending" // Se registró antes de ejecutarla, si queda así hay que revisar el mensaje
+	AuditStatusDone    AuditStatus = "done"    // Se ejecutó
+	AuditStatusFailed  AuditStatus = "failed"  // No se pudo ejecutar, el mensaje sigue en la DLQ
+)
+
+// Message es un mensaje de la DLQ con los datos de la falla
+type Message struct {
+	MessageId     string                 `json:"messageId"`
+	CorrelationId string                 `json:"correlationId,omitempty"`
+	Queue         string                 `json:"queue"`              // Cola original
+	Attempts      int                    `json:"attempts"`           // Intentos fallidos
+	Error         string                 `json:"error,omitempty"`    // Error del último intento
+	FailedAt      string                 `json:"failedAt,omitempty"` // Fecha del último intento
+	Headers       map[string]interface{} `json:"headers"`
+	ContentType   string                 `json:"contentType,omitempty"`
+	Body          string                 `json:"body"`
+}
+
+// AuditRecord registro de una acción sobre un mensaje de la DLQ
+type AuditRecord struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action        Action             `bson:"action" json:"action" validate:"required"`
+	Queue         string             `bson:"queue" json:"queue" validate:"required"` // Cola original
+	MessageId     string             `bson:"messageId" json:"messageId" validate:"required"`
+	CorrelationId string             `bson:"correlationId,omitempty" json:"correlationId,omitempty"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`   // Error del último intento del mensaje
+	Reason        string             `bson:"reason,omitempty" json:"reason,omitempty"` // Motivo, requerido para descartar
+	Body          string             `bson:"body" json:"body"`                         // Contenido del mensaje, queda guardado aunque se descarte
+	UserId        string             `bson:"userId" json:"userId" validate:"required"` // Usuario que ejecutó la acción
+	Status        AuditStatus        `bson:"status" json:"status" validate:"required"`
+	Failure       string             `bson:"failure,omitempty" json:"failure,omitempty"` // Error al ejecutar la acción
+	Created       time.Time          `bson:"created" json:"created"`
+	Finished      *time.Time         `bson:"finished,omitempty" json:"finished,omitempty"`
+}
+
+// Validate valida la estructura
+func (r *AuditRecord) Validate() error {
+	if err := validator.New().Struct(r); err != nil {
+		return err
+	}
+	if r.Action == ActionDiscard && len(r.Reason) == 0 {
+		return errs.NewValidation().Add("reason", "Se requiere el motivo para descartar un mensaje")
+	}
+	return nil
+}
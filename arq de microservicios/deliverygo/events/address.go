@@ -0,0 +1,75 @@
+package events
+
+import (
+	"regexp"
+	"strings"
+
+	"deliverygo/tools/errs"
+)
+
+// Address es la dirección de entrega del delivery
+type Address struct {
+	Recipient    string `bson:"recipient" json:"recipient"`       // Quién recibe
+	Street       string `bson:"street" json:"street"`             // Calle y número
+	City         string `bson:"city" json:"city"`                 // Ciudad
+	PostalCode   string `bson:"postalCode" json:"postalCode"`     // Código postal
+	Country      string `bson:"country" json:"country"`           // País
+	Phone        string `bson:"phone" json:"phone"`               // Teléfono de contacto, opcional
+	Instructions string `bson:"instructions" json:"instructions"` // Indicaciones para el courier, opcional
+}
+
+var phoneRegex = regexp.MustCompile(`^\+?[0-9 ()\-]{6,30}$`)
+
+// AddressFields nombres de los campos de la dirección en los errores de validación.
+// Cada entrada informa los errores con los nombres de su propio formato.
+type AddressFields struct {
+	Recipient    string
+	Street       string
+	City         string
+	PostalCode   string
+	Country      string
+	Phone        string
+	Instructions string
+}
+
+// AddressJSONFields nombres de los campos en el JSON de Address, los que usa REST
+var AddressJSONFields = AddressFields{
+	Recipient:    "recipient",
+	Street:       "street",
+	City:         "city",
+	PostalCode:   "postalCode",
+	Country:      "country",
+	Phone:        "phone",
+	Instructions: "instructions",
+}
+
+// AddValidations valida la dirección y agrega los errores en v, con path como prefijo de cada campo
+// y los nombres de fields
+func (a *Address) AddValidations(v errs.Validation, path string, fields AddressFields) {
+	required := []struct {
+		field string
+		value string
+		max   int
+	}{
+		{fields.Recipient, a.Recipient, 100},
+		{fields.Street, a.Street, 200},
+		{fields.City, a.City, 100},
+		{fields.PostalCode, a.PostalCode, 20},
+		{fields.Country, a.Country, 60},
+	}
+	for _, r := range required {
+		value := strings.TrimSpace(r.value)
+		if len(value) == 0 {
+			v.Add(path+"."+r.field, "Requerido")
+		} else if len(value) > r.max {
+			v.Add(path+"."+r.field, "Demasiado largo")
+		}
+	}
+
+	if len(a.Phone) > 0 && !phoneRegex.MatchString(a.Phone) {
+		v.Add(path+"."+fields.Phone, "Teléfono inválido")
+	}
+	if len(a.Instructions) > 500 {
+		v.Add(path+"."+fields.Instructions, "Demasiado largo")
+	}
+}
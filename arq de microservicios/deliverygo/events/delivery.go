@@ -0,0 +1,216 @@
+// Agregado Delivery, es el estado completo de un delivery reconstruido a partir de su stream de eventos.
+// Los comandos toman las decisiones sobre este agregado y no sobre la proyección.
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"deliverygo/tools/errs"
+)
+
+// ErrAssignmentNotAccepted la transición requiere que el courier asignado acepte el delivery
+var ErrAssignmentNotAccepted = errs.NewRestError(409, "Courier has not accepted the assignment")
+
+// Delivery es el estado de un delivery en una versión del stream
+type Delivery struct {
+	DeliveryId   string
+	OrderId      string
+	UserId       string // Dueño del delivery
+	CourierId    string // userId del courier asignado
+	Accepted     bool   // El courier asignado aceptó el delivery
+	Address      *Address
+	Status       DeliveryStatus
+	Version      int64 // Versión del último evento aplicado
+	Attempts     int   // Cantidad de veces que salió a entregarse
+	Created      time.Time
+	LastModified time.Time
+	DispatchedAt *time.Time
+	DeliveredAt  *time.Time
+	CancelledAt  *time.Time
+}
+
+// LoadDelivery busca el stream del delivery y lo reconstruye
+func LoadDelivery(deliveryId string, deps ...interface{}) (*Delivery, error) {
+	stream, err := FindDeliveryEventsByDeliveryId(deliveryId, deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDelivery(stream)
+}
+
+// NewDelivery reconstruye el delivery aplicando en orden los eventos del stream
+func NewDelivery(stream []*Event) (*Delivery, error) {
+	if len(stream) == 0 {
+		return nil, errs.NotFound
+	}
+
+	d := &Delivery{}
+	for _, e := range stream {
+		d.Apply(e)
+	}
+	return d, nil
+}
+
+// Apply aplica un evento sobre el estado del delivery
+func (d *Delivery) Apply(e *Event) {
+	d.DeliveryId = e.DeliveryId
+	if e.OrderId != "" {
+		d.OrderId = e.OrderId
+	}
+
+	switch e.Type {
+	case ConfirmDelivery:
+		d.Created = e.Created
+		if e.ConfirmDelivery != nil {
+			d.UserId = e.ConfirmDelivery.UserId
+			d.Address = e.ConfirmDelivery.Address
+		}
+	case SetOnTheGoDelivery:
+		d.Attempts++
+		d.DispatchedAt = timestamp(e)
+	case AssignCourier:
+		if e.AssignCourier != nil {
+			d.CourierId = e.AssignCourier.CourierId
+		}
+		d.Accepted = false
+	case UnassignCourier:
+		d.CourierId = ""
+		d.Accepted = false
+	case AcceptAssignment:
+		d.Accepted = true
+	case AddressChanged:
+		if e.AddressChanged != nil {
+			d.Address = e.AddressChanged.Address
+		}
+	case SetDeliveredDelivery:
+		d.DeliveredAt = timestamp(e)
+	case CancelledDelivery:
+		d.CancelledAt = timestamp(e)
+	}
+
+	d.Status = e.DeliveryStatus
+	d.LastModified = e.Created
+	// Los eventos anteriores al versionado no tienen versión
+	if e.Version > 0 {
+		d.Version = e.Version
+	}
+}
+
+// CanApply retorna true si el delivery cumple las condiciones de la transición además del estado,
+// por ahora que el courier asignado haya aceptado
+func (d *Delivery) CanApply(transition *Transition) bool {
+	return !transition.Accepted || (d.CourierId != "" && d.Accepted)
+}
+
+// NewStatusChangeEvent crea el evento que lleva al delivery al estado pedido, validando la transición.
+// El evento ocupa la siguiente versión del stream.
+func (d *Delivery) NewStatusChangeEvent(userId string, status DeliveryStatus) (*Event, error) {
+	transition, err := FindTransitionTo(d.Status, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEvent(d.DeliveryId, d.OrderId, userId, d.Version+1, transition), nil
+}
+
+// NewEvent crea un evento de cambio de estado del tipo indicado, validando la transición.
+// Los eventos que no cambian el estado tienen su propia función porque llevan más datos.
+func (d *Delivery) NewEvent(userId string, eventType EventType) (*Event, error) {
+	transition, err := FindTransition(d.Status, eventType)
+	if err != nil {
+		return nil, err
+	}
+	if !transition.ChangesStatus() {
+		return nil, errs.NewValidation().Add("type", fmt.Sprintf("invalid event type: %s", eventType))
+	}
+
+	return newEvent(d.DeliveryId, d.OrderId, userId, d.Version+1, transition), nil
+}
+
+// NewAssignCourierEvent crea el evento que asigna el courier al delivery, reemplazando al anterior si había uno
+func (d *Delivery) NewAssignCourierEvent(userId, courierId string) (*Event, error) {
+	transition, err := FindTransition(d.Status, AssignCourier)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(d.DeliveryId, d.OrderId, userId, d.Version+1, transition)
+	event.AssignCourier = &AssignCourierEvent{
+		CourierId: courierId,
+		UserId:    userId,
+		Timestamp: time.Now(),
+	}
+	return event, nil
+}
+
+// NewUnassignCourierEvent crea el evento que quita el courier asignado
+func (d *Delivery) NewUnassignCourierEvent(userId string) (*Event, error) {
+	if d.CourierId == "" {
+		return nil, errs.NewValidation().Add("courierId", "El delivery no tiene courier asignado")
+	}
+
+	transition, err := FindTransition(d.Status, UnassignCourier)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(d.DeliveryId, d.OrderId, userId, d.Version+1, transition)
+	event.UnassignCourier = &UnassignCourierEvent{
+		CourierId: d.CourierId,
+		UserId:    userId,
+		Timestamp: time.Now(),
+	}
+	return event, nil
+}
+
+// NewAcceptAssignmentEvent crea el evento con el que el courier asignado acepta el delivery
+func (d *Delivery) NewAcceptAssignmentEvent(userId string) (*Event, error) {
+	if d.CourierId == "" {
+		return nil, errs.NewValidation().Add("courierId", "El delivery no tiene courier asignado")
+	}
+	if d.Accepted {
+		return nil, errs.NewValidation().Add("courierId", "El courier ya aceptó el delivery")
+	}
+
+	transition, err := FindTransition(d.Status, AcceptAssignment)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(d.DeliveryId, d.OrderId, userId, d.Version+1, transition)
+	event.AcceptAssignment = &AcceptAssignmentEvent{
+		CourierId: d.CourierId,
+		UserId:    userId,
+		Timestamp: time.Now(),
+	}
+	return event, nil
+}
+
+// NewAddressChangedEvent crea el evento que reemplaza la dirección de entrega
+func (d *Delivery) NewAddressChangedEvent(userId string, address *Address) (*Event, error) {
+	v := errs.NewValidation()
+	address.AddValidations(v, "address", AddressJSONFields)
+	if v.HasErrors() {
+		return nil, v
+	}
+
+	transition, err := FindTransition(d.Status, AddressChanged)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(d.DeliveryId, d.OrderId, userId, d.Version+1, transition)
+	event.AddressChanged = &AddressChangedEvent{
+		Address:   address,
+		UserId:    userId,
+		Timestamp: time.Now(),
+	}
+	return event, nil
+}
+
+func timestamp(e *Event) *time.Time {
+	t := e.Created
+	return &t
+}
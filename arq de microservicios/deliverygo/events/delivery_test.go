@@ -0,0 +1,134 @@
+package events
+
+import (
+	"testing"
+
+	"deliverygo/tools/errs"
+)
+
+// step genera el siguiente evento del stream a partir del estado del delivery
+type step func(d *Delivery) (*Event, error)
+
+func assign(courierId string) step {
+	return func(d *Delivery) (*Event, error) { return d.NewAssignCourierEvent("admin", courierId) }
+}
+
+func accept(d *Delivery) (*Event, error) { return d.NewAcceptAssignmentEvent(d.CourierId) }
+
+func unassign(d *Delivery) (*Event, error) { return d.NewUnassignCourierEvent(d.CourierId) }
+
+func status(s DeliveryStatus) step {
+	return func(d *Delivery) (*Event, error) { return d.NewStatusChangeEvent("user", s) }
+}
+
+func changeAddress(street string) step {
+	return func(d *Delivery) (*Event, error) { return d.NewAddressChangedEvent("owner", testAddress(street)) }
+}
+
+func testAddress(street string) *Address {
+	return &Address{Recipient: "Juan", Street: street, City: "Córdoba", PostalCode: "5000", Country: "AR"}
+}
+
+// buildStream arma el stream aplicando cada paso sobre el delivery reconstruido hasta el momento
+func buildStream(t *testing.T, steps ...step) []*Event {
+	t.Helper()
+	stream := []*Event{NewConfirmDeliveryEvent("d1", "o1", "owner", testAddress("Calle 1"), "")}
+	for _, s := range steps {
+		d, err := NewDelivery(stream)
+		if err != nil {
+			t.Fatalf("NewDelivery: %v", err)
+		}
+		e, err := s(d)
+		if err != nil {
+			t.Fatalf("paso %d: %v", len(stream), err)
+		}
+		stream = append(stream, e)
+	}
+	return stream
+}
+
+func TestNewDeliveryFoldsStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []step
+		status    DeliveryStatus
+		courierId string
+		accepted  bool
+		street    string
+		attempts  int
+		canGo     bool // Puede salir a entregarse
+	}{
+		{"confirmado", nil, DeliveryStatusConfirmed, "", false, "Calle 1", 0, false},
+		{"asignado sin aceptar", []step{assign("c1")}, DeliveryStatusConfirmed, "c1", false, "Calle 1", 0, false},
+		{"asignado y aceptado", []step{assign("c1"), accept}, DeliveryStatusConfirmed, "c1", true, "Calle 1", 0, true},
+		{"reasignado pierde la aceptación", []step{assign("c1"), accept, assign("c2")}, DeliveryStatusConfirmed, "c2", false, "Calle 1", 0, false},
+		{"rechazado", []step{assign("c1"), unassign}, DeliveryStatusConfirmed, "", false, "Calle 1", 0, false},
+		{"dirección cambiada", []step{changeAddress("Calle 2")}, DeliveryStatusConfirmed, "", false, "Calle 2", 0, false},
+		{"en camino", []step{assign("c1"), accept, status(DeliveryStatusOnTheGo)}, DeliveryStatusOnTheGo, "c1", true, "Calle 1", 1, false},
+		{"entregado", []step{assign("c1"), accept, status(DeliveryStatusOnTheGo), status(DeliveryStatusDelivered)}, DeliveryStatusDelivered, "c1", true, "Calle 1", 1, false},
+		{"cancelado", []step{status(DeliveryStatusCancelled)}, DeliveryStatusCancelled, "", false, "Calle 1", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := buildStream(t, tt.steps...)
+			d, err := NewDelivery(stream)
+			if err != nil {
+				t.Fatalf("NewDelivery: %v", err)
+			}
+
+			if d.DeliveryId != "d1" || d.OrderId != "o1" || d.UserId != "owner" {
+				t.Errorf("ids = %s %s %s", d.DeliveryId, d.OrderId, d.UserId)
+			}
+			if d.Status != tt.status {
+				t.Errorf("Status = %s, se esperaba %s", d.Status, tt.status)
+			}
+			if d.Version != int64(len(stream)) {
+				t.Errorf("Version = %d, se esperaba %d", d.Version, len(stream))
+			}
+			if d.CourierId != tt.courierId || d.Accepted != tt.accepted {
+				t.Errorf("CourierId = %q, Accepted = %v", d.CourierId, d.Accepted)
+			}
+			if d.Address.Street != tt.street {
+				t.Errorf("Street = %q, se esperaba %q", d.Address.Street, tt.street)
+			}
+			if d.Attempts != tt.attempts {
+				t.Errorf("Attempts = %d, se esperaba %d", d.Attempts, tt.attempts)
+			}
+
+			if transition, err := FindTransition(d.Status, SetOnTheGoDelivery); err == nil {
+				if got := d.CanApply(transition); got != tt.canGo {
+					t.Errorf("CanApply(on_the_go) = %v, se esperaba %v", got, tt.canGo)
+				}
+			} else if tt.canGo {
+				t.Errorf("FindTransition(on_the_go): %v", err)
+			}
+		})
+	}
+}
+
+func TestNewDeliveryEmptyStream(t *testing.T) {
+	if _, err := NewDelivery(nil); err != errs.NotFound {
+		t.Errorf("err = %v, se esperaba NotFound", err)
+	}
+}
+
+func TestApplyWithoutVersion(t *testing.T) {
+	// Los eventos anteriores al versionado no tienen versión y no pisan la última conocida
+	stream := buildStream(t, assign("c1"))
+	stream[1].Version = 0
+
+	d, err := NewDelivery(stream)
+	if err != nil {
+		t.Fatalf("NewDelivery: %v", err)
+	}
+	if d.Version != 1 {
+		t.Errorf("Version = %d, se esperaba 1", d.Version)
+	}
+}
+
+func TestErrAssignmentNotAccepted(t *testing.T) {
+	if ErrAssignmentNotAccepted.Status() != 409 {
+		t.Errorf("Status() = %d, se esperaba 409", ErrAssignmentNotAccepted.Status())
+	}
+}
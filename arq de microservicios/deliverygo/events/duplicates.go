@@ -0,0 +1,136 @@
+// Deliveries duplicados, creados antes de los índices únicos de orderId y correlationId.
+// Mientras existan los índices no se pueden crear y el servicio no inicia, se limpian con
+// deliverygo dedupe-deliveries.
+package events
+
+import (
+	"context"
+	"time"
+
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// duplicatesCollectionName colección donde se archivan los streams duplicados que se quitan del event store
+const duplicatesCollectionName = "deliveryEventsDuplicates"
+
+// DuplicateDeliveries son los deliveries confirmados para la misma orden o por el mismo mensaje
+type DuplicateDeliveries struct {
+	Field      string              `json:"field"` // orderId o correlationId
+	Value      string              `json:"value"`
+	Deliveries []DuplicateDelivery `json:"deliveries"` // Del más antiguo al más nuevo, se conserva el primero
+}
+
+// DuplicateDelivery es uno de los deliveries duplicados
+type DuplicateDelivery struct {
+	DeliveryId string    `json:"deliveryId" bson:"deliveryId"`
+	Created    time.Time `json:"created" bson:"created"`
+}
+
+// FindDuplicateDeliveries busca las órdenes y los mensajes con más de un evento de confirmación
+func FindDuplicateDeliveries(deps ...interface{}) ([]*DuplicateDeliveries, error) {
+	col, err := eventsCollection(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	byOrder, err := findDuplicatesBy(col, "orderId", "$orderId", bson.M{}, deps...)
+	if err != nil {
+		return nil, err
+	}
+	byMessage, err := findDuplicatesBy(col, "correlationId", "$confirmDeliveryEvent.correlationId", bson.M{
+		"confirmDeliveryEvent.correlationId": bson.M{"$exists": true},
+	}, deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(byOrder, byMessage...), nil
+}
+
+// findDuplicatesBy agrupa los eventos de confirmación por el campo y retorna los grupos con más de un delivery
+func findDuplicatesBy(col *mongo.Collection, field, groupKey string, filter bson.M, deps ...interface{}) ([]*DuplicateDeliveries, error) {
+	filter["type"] = ConfirmDelivery
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        groupKey,
+			"deliveries": bson.M{"$push": bson.M{"deliveryId": "$deliveryId", "created": "$created"}},
+		}}},
+		{{Key: "$match", Value: bson.M{"deliveries.1": bson.M{"$exists": true}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cur, err := col.Aggregate(context.Background(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := []*DuplicateDeliveries{}
+	for cur.Next(context.Background()) {
+		group := struct {
+			Value      string              `bson:"_id"`
+			Deliveries []DuplicateDelivery `bson:"deliveries"`
+		}{}
+		if err := cur.Decode(&group); err != nil {
+			log.Get(deps...).Error(err)
+			return nil, err
+		}
+		result = append(result, &DuplicateDeliveries{Field: field, Value: group.Value, Deliveries: group.Deliveries})
+	}
+
+	return result, cur.Err()
+}
+
+// ArchiveDeliveryStream mueve los eventos del delivery a deliveryEventsDuplicates y los quita
+// del event store, en una transacción. Retorna la cantidad de eventos movidos.
+func ArchiveDeliveryStream(deliveryId string, deps ...interface{}) (int, error) {
+	col, err := eventsCollection(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return 0, err
+	}
+	archive := col.Database().Collection(duplicatesCollectionName)
+
+	session, err := col.Database().Client().StartSession()
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return 0, err
+	}
+	defer session.EndSession(context.Background())
+
+	moved, err := session.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		cur, err := col.Find(sc, bson.M{"deliveryId": deliveryId})
+		if err != nil {
+			return 0, err
+		}
+		stream := []interface{}{}
+		if err := cur.All(sc, &stream); err != nil {
+			return 0, err
+		}
+		if len(stream) == 0 {
+			return 0, nil
+		}
+
+		if _, err := archive.InsertMany(sc, stream); err != nil {
+			return 0, err
+		}
+		if _, err := col.DeleteMany(sc, bson.M{"deliveryId": deliveryId}); err != nil {
+			return 0, err
+		}
+		return len(stream), nil
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return 0, err
+	}
+
+	return moved.(int), nil
+}
@@ -0,0 +1,270 @@
+// Outbox de eventos. Cada evento que se guarda en deliveryEvents genera, en la misma transacción,
+// un mensaje pendiente en deliveryOutbox. Un proceso aparte los publica en RabbitMQ y los marca como enviados,
+// así una caída entre el insert y la publicación no pierde notificaciones.
+package events
+
+import (
+	"context"
+	"time"
+
+	"deliverygo/tools/db"
+	"deliverygo/tools/log"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OutboxStatus estado de un mensaje del outbox
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSent    OutboxStatus = "sent"
+)
+
+// OutboxSentTTL tiempo que se guardan los mensajes enviados, después los borra el índice TTL
+const OutboxSentTTL = 7 * 24 * time.Hour
+
+// Espera mínima de un mensaje que tiene pendiente un evento anterior del mismo delivery
+const outboxBlockedDelay = time.Second
+
+// OutboxMessage es un evento pendiente de publicar
+type OutboxMessage struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Event         *Event             `bson:"event"`         // Copia del evento guardado
+	CorrelationId string             `bson:"correlationId"` // Correlation id del request que generó el evento
+	Status        OutboxStatus       `bson:"status"`
+	Attempts      int                `bson:"attempts"`    // Intentos de publicación fallidos
+	NextAttempt   time.Time          `bson:"nextAttempt"` // No se reintenta antes de esta fecha
+	LastError     string             `bson:"lastError,omitempty"`
+	ClaimedBy     string             `bson:"claimedBy,omitempty"`    // Relay que lo está publicando
+	ClaimedUntil  *time.Time         `bson:"claimedUntil,omitempty"` // Otro relay lo puede tomar después de esta fecha
+	Created       time.Time          `bson:"created"`
+	SentAt        *time.Time         `bson:"sentAt,omitempty"` // Se borra OutboxSentTTL después de esta fecha
+}
+
+var outboxCollection *mongo.Collection
+
+// Configura y devuelve la colección deliveryOutbox de MongoDB.
+func dbOutboxCollection(deps ...interface{}) (*mongo.Collection, error) {
+	if outboxCollection != nil {
+		return outboxCollection, nil
+	}
+
+	database, err := db.Get(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	col := database.Collection("deliveryOutbox")
+
+	_, err = col.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "status", Value: 1},      // Se buscan los pendientes
+					{Key: "nextAttempt", Value: 1}, // que ya se pueden reintentar
+				},
+			},
+			{
+				Keys: bson.D{
+					{Key: "event.deliveryId", Value: 1}, // Se buscan los pendientes anteriores
+					{Key: "status", Value: 1},           // del mismo delivery
+					{Key: "event.version", Value: 1},
+				},
+			},
+			{
+				// Los pendientes no tienen sentAt y no expiran
+				Keys:    bson.M{"sentAt": 1},
+				Options: options.Index().SetExpireAfterSeconds(int32(OutboxSentTTL.Seconds())),
+			},
+		},
+	)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	outboxCollection = col
+	return outboxCollection, nil
+}
+
+// newOutboxMessage arma el mensaje pendiente para un evento
+func newOutboxMessage(event *Event, deps ...interface{}) *OutboxMessage {
+	corrId, ok := log.Get(deps...).Data[log.LOG_FIELD_CORRELATION_ID].(string)
+	if !ok || len(corrId) == 0 {
+		corrId = uuid.NewV4().String()
+	}
+
+	return &OutboxMessage{
+		ID:            primitive.NewObjectID(),
+		Event:         event,
+		CorrelationId: corrId,
+		Status:        OutboxStatusPending,
+		NextAttempt:   time.Now(),
+		Created:       time.Now(),
+	}
+}
+
+// ClaimPendingOutbox toma el próximo mensaje pendiente que ya se puede publicar, en orden de creación,
+// y lo reserva para owner hasta que pase lease. Con varias réplicas del relay cada mensaje lo toma
+// una sola, si la réplica se cae antes de marcarlo otra lo toma al vencer la reserva.
+// Los eventos de un delivery se publican en orden: si queda pendiente uno anterior del mismo delivery
+// el mensaje se posterga y se toma el siguiente.
+// Retorna nil si no hay mensajes para publicar.
+func ClaimPendingOutbox(owner string, lease time.Duration, deps ...interface{}) (*OutboxMessage, error) {
+	col, err := dbOutboxCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		message, err := claimNextOutbox(col, owner, lease, deps...)
+		if message == nil || err != nil {
+			return nil, err
+		}
+
+		previous, err := findPreviousPending(col, message, deps...)
+		if err != nil {
+			return nil, err
+		}
+		if previous == nil {
+			return message, nil
+		}
+
+		// Cada mensaje postergado queda con nextAttempt en el futuro, el loop no lo vuelve a tomar
+		if err := postponeOutbox(col, message, previous, deps...); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// claimNextOutbox reserva el próximo mensaje pendiente, nil si no hay
+func claimNextOutbox(col *mongo.Collection, owner string, lease time.Duration, deps ...interface{}) (*OutboxMessage, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":      OutboxStatusPending,
+		"nextAttempt": bson.M{"$lte": now},
+		"$or": []bson.M{
+			{"claimedUntil": bson.M{"$exists": false}},
+			{"claimedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"claimedBy":    owner,
+			"claimedUntil": now.Add(lease),
+		},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	message := &OutboxMessage{}
+	err := col.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	return message, nil
+}
+
+// findPreviousPending busca un mensaje sin enviar de una versión anterior del mismo delivery, nil si no hay
+func findPreviousPending(col *mongo.Collection, m *OutboxMessage, deps ...interface{}) (*OutboxMessage, error) {
+	filter := bson.M{
+		"event.deliveryId": m.Event.DeliveryId,
+		"status":           OutboxStatusPending,
+		"event.version":    bson.M{"$lt": m.Event.Version},
+	}
+
+	previous := &OutboxMessage{}
+	err := col.FindOne(context.Background(), filter).Decode(previous)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	return previous, nil
+}
+
+// postponeOutbox libera la reserva del mensaje y lo posterga hasta que se pueda publicar el anterior.
+// No cuenta como intento fallido.
+func postponeOutbox(col *mongo.Collection, m, previous *OutboxMessage, deps ...interface{}) error {
+	next := time.Now().Add(outboxBlockedDelay)
+	if previous.NextAttempt.After(next) {
+		next = previous.NextAttempt
+	}
+	if previous.ClaimedUntil != nil && previous.ClaimedUntil.After(next) {
+		next = *previous.ClaimedUntil
+	}
+
+	_, err := col.UpdateOne(context.Background(), claimFilter(m), bson.M{
+		"$set":   bson.M{"nextAttempt": next},
+		"$unset": bson.M{"claimedBy": "", "claimedUntil": ""},
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+	}
+	return err
+}
+
+// MarkOutboxSent marca el mensaje como publicado y libera la reserva
+func MarkOutboxSent(m *OutboxMessage, deps ...interface{}) error {
+	col, err := dbOutboxCollection(deps...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = col.UpdateOne(context.Background(), claimFilter(m), bson.M{
+		"$set": bson.M{
+			"status": OutboxStatusSent,
+			"sentAt": now,
+		},
+		"$unset": bson.M{"claimedBy": "", "claimedUntil": ""},
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+	}
+	return err
+}
+
+// MarkOutboxFailed registra un intento fallido, cuándo se puede volver a intentar y libera la reserva
+func MarkOutboxFailed(m *OutboxMessage, cause error, nextAttempt time.Time, deps ...interface{}) error {
+	col, err := dbOutboxCollection(deps...)
+	if err != nil {
+		return err
+	}
+
+	_, err = col.UpdateOne(context.Background(), claimFilter(m), bson.M{
+		"$set": bson.M{
+			"lastError":   cause.Error(),
+			"nextAttempt": nextAttempt,
+		},
+		"$inc":   bson.M{"attempts": 1},
+		"$unset": bson.M{"claimedBy": "", "claimedUntil": ""},
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+	}
+	return err
+}
+
+// claimFilter busca el mensaje solo si sigue reservado por el mismo relay. Si la reserva venció
+// y lo tomó otro, el resultado lo registra el nuevo dueño.
+func claimFilter(m *OutboxMessage) bson.M {
+	return bson.M{
+		"_id":       m.ID,
+		"claimedBy": m.ClaimedBy,
+	}
+}
@@ -3,34 +3,63 @@ package events
 import (
 	"context"
 	"deliverygo/tools/db"
+	"deliverygo/tools/errs"
 	"deliverygo/tools/log"
 	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var collection *mongo.Collection
 
+// ErrVersionConflict se produce cuando otro evento ya ocupó la versión que se quería insertar
+var ErrVersionConflict = errs.NewRestError(409, "Delivery was modified concurrently")
+
+// ErrDuplicateDelivery ya existe un delivery para la orden o creado por el mismo mensaje
+var ErrDuplicateDelivery = errs.NewRestError(409, "Delivery already exists for the order")
+
+// ErrDuplicateDeliveries hay deliveries duplicados en deliveryEvents y no se pueden crear los índices únicos
+var ErrDuplicateDeliveries = errs.NewRestError(500, "hay deliveries duplicados en deliveryEvents, revisarlos con deliverygo dedupe-deliveries")
+
 // Configura y devuelve la colección deliveryEvents de MongoDB.
 func dbCollection(deps ...interface{}) (*mongo.Collection, error) {
 	if collection != nil {
 		return collection, nil
 	}
 
-	database, err := db.Get(deps...)
+	col, err := eventsCollection(deps...)
 	if err != nil {
 		log.Get(deps...).Error(err)
 		return nil, err
 	}
 
-	col := database.Collection("deliveryEvents")
-
-	_, err = col.Indexes().CreateOne(
+	_, err = col.Indexes().CreateMany(
 		context.Background(),
-		mongo.IndexModel{
-			Keys: bson.M{
-				"deliveryId": 1, // Índice en deliveryId
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "deliveryId", Value: 1}, // Índice en deliveryId
+					{Key: "version", Value: 1},    // Una sola versión por delivery, mantiene el stream lineal
+				},
+				// Los eventos anteriores al versionado quedan en 0 y no participan del índice
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+					"version": bson.M{"$gt": 0},
+				}),
+			},
+			{
+				// Orden de los streams al recorrerlos completos. El índice único es parcial y el
+				// sort no lo puede usar porque la consulta incluye los eventos sin versión.
+				Keys: bson.D{
+					{Key: "deliveryId", Value: 1},
+					{Key: "version", Value: 1},
+					{Key: "created", Value: 1},
+				},
+			},
+			{
+				Keys: bson.M{"created": 1}, // Eventos guardados desde una fecha
 			},
 		},
 	)
@@ -39,11 +68,64 @@ func dbCollection(deps ...interface{}) (*mongo.Collection, error) {
 		return nil, err
 	}
 
+	if err := createUniqueDeliveryIndexes(col, deps...); err != nil {
+		return nil, err
+	}
+
 	collection = col
 	return collection, nil
 }
 
-// Insertar un evento de Delivery en la collection de mongo
+// eventsCollection retorna la colección deliveryEvents sin crear los índices, la usa la
+// limpieza de duplicados que se tiene que poder ejecutar cuando los índices únicos fallan
+func eventsCollection(deps ...interface{}) (*mongo.Collection, error) {
+	database, err := db.Get(deps...)
+	if err != nil {
+		return nil, err
+	}
+	return database.Collection("deliveryEvents"), nil
+}
+
+// EnsureIndexes crea los índices de deliveryEvents. Se llama al iniciar el servicio para que no
+// arranque si hay deliveries duplicados que impiden crear los índices únicos.
+func EnsureIndexes(deps ...interface{}) error {
+	_, err := dbCollection(deps...)
+	return err
+}
+
+// createUniqueDeliveryIndexes crea los índices que impiden dos deliveries para la misma orden
+// o para el mismo mensaje. Solo participan los eventos de confirmación, que inician el stream.
+// Si hay duplicados anteriores a los índices no se pueden crear y retorna ErrDuplicateDeliveries.
+func createUniqueDeliveryIndexes(col *mongo.Collection, deps ...interface{}) error {
+	_, err := col.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.M{"orderId": 1}, // Un delivery por orden
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+					"type": ConfirmDelivery,
+				}),
+			},
+			{
+				Keys: bson.M{"confirmDeliveryEvent.correlationId": 1}, // Un delivery por mensaje
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+					"confirmDeliveryEvent.correlationId": bson.M{"$exists": true},
+				}),
+			},
+		},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		log.Get(deps...).Error(err)
+		return ErrDuplicateDeliveries
+	}
+	if err != nil {
+		log.Get(deps...).Error(err)
+	}
+	return err
+}
+
+// Insertar un evento de Delivery en la collection de mongo.
+// En la misma transacción se guarda el mensaje del outbox para publicarlo en RabbitMQ.
 func InsertDeliveryEvent(event *Event, deps ...interface{}) (*Event, error) {
 	if err := event.ValidateSchema(); err != nil { //valida el esquema del evento
 		log.Get(deps...).Error(err)
@@ -63,10 +145,36 @@ func InsertDeliveryEvent(event *Event, deps ...interface{}) (*Event, error) {
 		return nil, err
 	}
 
-	if _, err := collection.InsertOne(context.Background(), event); err != nil {
+	outbox, err := dbOutboxCollection(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	session, err := collection.Database().Client().StartSession()
+	if err != nil {
 		log.Get(deps...).Error(err)
 		return nil, err
 	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := collection.InsertOne(sc, event); err != nil {
+			return nil, err
+		}
+		return outbox.InsertOne(sc, newOutboxMessage(event, deps...))
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+		if db.IsUniqueKeyError(err) {
+			// El primer evento de un stream nuevo solo choca con otro delivery de la misma orden o mensaje
+			if event.Type == ConfirmDelivery {
+				return nil, ErrDuplicateDelivery
+			}
+			return nil, ErrVersionConflict
+		}
+		return nil, err
+	}
 
 	return event, nil
 }
@@ -103,7 +211,7 @@ func FindDeliveryEventsByStatus(deliveryStatus string, deps ...interface{}) ([]*
 	return events, nil
 }
 
-// Buscar eventos relacionados a un deliveryId
+// Buscar eventos relacionados a un deliveryId, ordenados por versión
 func FindDeliveryEventsByDeliveryId(deliveryId string, deps ...interface{}) ([]*Event, error) {
 	var collection, err = dbCollection(deps...)
 	if err != nil {
@@ -112,7 +220,8 @@ func FindDeliveryEventsByDeliveryId(deliveryId string, deps ...interface{}) ([]*
 	}
 
 	filter := bson.M{"deliveryId": deliveryId}
-	cur, err := collection.Find(context.Background(), filter, nil)
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}, {Key: "created", Value: 1}})
+	cur, err := collection.Find(context.Background(), filter, opts)
 	if err != nil {
 		log.Get(deps...).Error(err)
 		return nil, err
@@ -131,40 +240,112 @@ func FindDeliveryEventsByDeliveryId(deliveryId string, deps ...interface{}) ([]*
 
 	return events, nil
 }
+
+// FindDeliveryIdByOrderId busca el delivery de una orden por su evento de confirmación.
+// Si quedaron duplicados anteriores al índice único retorna el más antiguo.
 func FindDeliveryIdByOrderId(orderId string, ctx ...interface{}) (string, error) {
-	var collection, err = dbCollection(ctx...)
+	event, err := findConfirmEvent(bson.M{"orderId": orderId}, ctx...)
 	if err != nil {
-		log.Get(ctx...).Error(err)
 		return "", err
 	}
+	return event.DeliveryId, nil
+}
 
-	// Filtrar por orderId
-	filter := bson.M{"orderId": orderId}
-	cur, err := collection.Find(context.Background(), filter, nil)
+// FindConfirmEventByCorrelationId busca el evento de confirmación del delivery creado por el
+// mensaje con ese correlation_id
+func FindConfirmEventByCorrelationId(correlationId string, ctx ...interface{}) (*Event, error) {
+	return findConfirmEvent(bson.M{"confirmDeliveryEvent.correlationId": correlationId}, ctx...)
+}
+
+// findConfirmEvent retorna el primer evento de confirmación que cumple el filtro
+func findConfirmEvent(filter bson.M, ctx ...interface{}) (*Event, error) {
+	var collection, err = dbCollection(ctx...)
 	if err != nil {
 		log.Get(ctx...).Error(err)
-		return "", err
+		return nil, err
+	}
+
+	filter["type"] = ConfirmDelivery
+	opts := options.FindOne().SetSort(bson.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}})
+	event := &Event{}
+	if err := collection.FindOne(context.Background(), filter, opts).Decode(event); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errs.NotFound
+		}
+		log.Get(ctx...).Error(err)
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// FindDeliveryIdsSince busca los deliveries que tienen eventos guardados desde since
+func FindDeliveryIdsSince(since time.Time, deps ...interface{}) ([]string, error) {
+	var collection, err = dbCollection(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	values, err := collection.Distinct(context.Background(), "deliveryId", bson.M{"created": bson.M{"$gte": since}})
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	result := []string{}
+	for _, value := range values {
+		if deliveryId, ok := value.(string); ok {
+			result = append(result, deliveryId)
+		}
+	}
+	return result, nil
+}
+
+// ForEachDeliveryStream recorre todos los streams del event store, llamando a fn con los eventos
+// de cada delivery ordenados por versión. Si fn retorna error se corta el recorrido.
+func ForEachDeliveryStream(fn func(stream []*Event) error, deps ...interface{}) error {
+	var collection, err = dbCollection(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{
+		{Key: "deliveryId", Value: 1},
+		{Key: "version", Value: 1},
+		{Key: "created", Value: 1},
+	})
+	cur, err := collection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return err
 	}
 	defer cur.Close(context.Background())
 
-	// Buscar el deliveryId asociado
-	var deliveryId string
+	stream := []*Event{}
 	for cur.Next(context.Background()) {
 		event := &Event{}
 		if err := cur.Decode(event); err != nil {
-			log.Get(ctx...).Error(err)
-			return "", err
+			log.Get(deps...).Error(err)
+			return err
 		}
-		// Asignar el deliveryId si existe
-		deliveryId = event.DeliveryId
-		break
-		// Solo necesitamos un `deliveryId`, salimos del bucle
-	}
 
-	// Verificar si se encontró un deliveryId
-	if deliveryId == "" {
-		return "", fmt.Errorf("no deliveryId found for orderId: %s", orderId)
+		if len(stream) > 0 && stream[0].DeliveryId != event.DeliveryId {
+			if err := fn(stream); err != nil {
+				return err
+			}
+			stream = []*Event{}
+		}
+		stream = append(stream, event)
+	}
+	if err := cur.Err(); err != nil {
+		log.Get(deps...).Error(err)
+		return err
 	}
 
-	return deliveryId, nil
+	if len(stream) > 0 {
+		return fn(stream)
+	}
+	return nil
 }
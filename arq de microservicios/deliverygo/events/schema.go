@@ -28,6 +28,20 @@ func (ds DeliveryStatus) IsValid() bool {
 	return false
 }
 
+// InvalidTransitionError indica que el estado actual del delivery no permite pasar al estado pedido
+type InvalidTransitionError struct {
+	Current DeliveryStatus
+	Target  DeliveryStatus
+	Event   EventType // Evento pedido, si la transición se buscó por evento
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if e.Event != "" {
+		return fmt.Sprintf("cannot apply %s to delivery with current status: %s", e.Event, e.Current)
+	}
+	return fmt.Sprintf("cannot set delivery to %s with current status: %s", e.Target, e.Current)
+}
+
 // EventType define los tipos de eventos manejados
 type EventType string
 
@@ -36,11 +50,15 @@ const (
 	CancelledDelivery    EventType = "cancelled_delivery"
 	SetOnTheGoDelivery   EventType = "set_onthego_delivery"
 	SetDeliveredDelivery EventType = "set_delivered_delivery"
+	AssignCourier        EventType = "assign_courier"
+	UnassignCourier      EventType = "unassign_courier"
+	AcceptAssignment     EventType = "accept_courier_assignment"
+	AddressChanged       EventType = "address_changed"
 )
 
 func (et EventType) IsValid() bool {
 	switch et {
-	case ConfirmDelivery, CancelledDelivery, SetOnTheGoDelivery, SetDeliveredDelivery:
+	case ConfirmDelivery, CancelledDelivery, SetOnTheGoDelivery, SetDeliveredDelivery, AssignCourier, UnassignCourier, AcceptAssignment, AddressChanged:
 		return true
 	}
 	return false
@@ -53,11 +71,17 @@ type Event struct {
 	OrderId              string                     `bson:"orderId" validate:"required"`    // ID de la orden asociada
 	DeliveryStatus       DeliveryStatus             `bson:"deliveryStatus" validate:"required"`
 	Type                 EventType                  `bson:"type" validate:"required"` // Tipo de evento
+	Version              int64                      `bson:"version" validate:"min=1"` // Posición del evento dentro del stream del delivery
 	ConfirmDelivery      *ConfirmDeliveryEvent      `bson:"confirmDeliveryEvent"`     // Datos del evento específico
 	CancelledDelivery    *CancelledDeliveryEvent    `bson:"cancelledDeliveryEvent"`
 	SetOnTheGoDelivery   *SetOnTheGoDeliveryEvent   `bson:"setOnTheGoDeliveryEvent"`
 	SetDeliveredDelivery *SetDeliveredDeliveryEvent `bson:"setDeliveredDeliveryEvent"`
-	Created              time.Time                  `bson:"created"` // Fecha de creación del evento
+	AssignCourier        *AssignCourierEvent        `bson:"assignCourierEvent"`
+	UnassignCourier      *UnassignCourierEvent      `bson:"unassignCourierEvent"`
+	AcceptAssignment     *AcceptAssignmentEvent     `bson:"acceptAssignmentEvent"`
+	AddressChanged       *AddressChangedEvent       `bson:"addressChangedEvent"`
+	ServiceId            string                     `bson:"serviceId,omitempty"` // Servicio que generó el evento en nombre del usuario
+	Created              time.Time                  `bson:"created"`             // Fecha de creación del evento
 }
 
 // ValidateSchema valida que los datos del evento sean correctos antes de insertarlo
@@ -76,9 +100,58 @@ func (e *Event) ValidateSchema() error {
 	return nil
 }
 
+// ActorId retorna el usuario que generó el evento
+func (e *Event) ActorId() string {
+	switch {
+	case e.ConfirmDelivery != nil:
+		return e.ConfirmDelivery.UserId
+	case e.CancelledDelivery != nil:
+		return e.CancelledDelivery.UserId
+	case e.SetOnTheGoDelivery != nil:
+		return e.SetOnTheGoDelivery.UserId
+	case e.SetDeliveredDelivery != nil:
+		return e.SetDeliveredDelivery.UserId
+	case e.AssignCourier != nil:
+		return e.AssignCourier.UserId
+	case e.UnassignCourier != nil:
+		return e.UnassignCourier.UserId
+	case e.AcceptAssignment != nil:
+		return e.AcceptAssignment.UserId
+	case e.AddressChanged != nil:
+		return e.AddressChanged.UserId
+	}
+	return ""
+}
+
+// Timestamp retorna la fecha registrada en los datos específicos del evento
+func (e *Event) Timestamp() time.Time {
+	switch {
+	case e.ConfirmDelivery != nil:
+		return e.ConfirmDelivery.Timestamp
+	case e.CancelledDelivery != nil:
+		return e.CancelledDelivery.Timestamp
+	case e.SetOnTheGoDelivery != nil:
+		return e.SetOnTheGoDelivery.Timestamp
+	case e.SetDeliveredDelivery != nil:
+		return e.SetDeliveredDelivery.Timestamp
+	case e.AssignCourier != nil:
+		return e.AssignCourier.Timestamp
+	case e.UnassignCourier != nil:
+		return e.UnassignCourier.Timestamp
+	case e.AcceptAssignment != nil:
+		return e.AcceptAssignment.Timestamp
+	case e.AddressChanged != nil:
+		return e.AddressChanged.Timestamp
+	}
+	return e.Created
+}
+
 // ConfirmDeliveryEvent define los datos específicos de confirmación
 type ConfirmDeliveryEvent struct {
-	Timestamp time.Time `bson:"timestamp"` // Fecha de la confirmación
+	UserId        string    `bson:"userId"`                  // ID del usuario dueño del delivery
+	Address       *Address  `bson:"address"`                 // Dirección de entrega
+	CorrelationId string    `bson:"correlationId,omitempty"` // correlation_id del mensaje que pidió la creación
+	Timestamp     time.Time `bson:"timestamp"`               // Fecha de la confirmación
 }
 type CancelledDeliveryEvent struct {
 	UserId    string    `bson:"userId" validate:"required"` // ID del usuario que cancela
@@ -94,3 +167,31 @@ type SetDeliveredDeliveryEvent struct {
 	UserId    string    `bson:"userId" validate:"required"` // ID del usuario que marca como entregado
 	Timestamp time.Time `bson:"timestamp"`                  // Fecha del cambio
 }
+
+// AssignCourierEvent asigna un courier al delivery. CourierId es el userId del courier.
+type AssignCourierEvent struct {
+	CourierId string    `bson:"courierId" validate:"required"` // Courier asignado
+	UserId    string    `bson:"userId" validate:"required"`    // ID del usuario que asigna
+	Timestamp time.Time `bson:"timestamp"`                     // Fecha del cambio
+}
+
+// UnassignCourierEvent quita el courier asignado al delivery, lo genera un admin o el courier al rechazar la asignación
+type UnassignCourierEvent struct {
+	CourierId string    `bson:"courierId" validate:"required"` // Courier que se quita
+	UserId    string    `bson:"userId" validate:"required"`    // ID del usuario que quita la asignación
+	Timestamp time.Time `bson:"timestamp"`                     // Fecha del cambio
+}
+
+// AcceptAssignmentEvent el courier asignado acepta llevar el delivery
+type AcceptAssignmentEvent struct {
+	CourierId string    `bson:"courierId" validate:"required"` // Courier que acepta
+	UserId    string    `bson:"userId" validate:"required"`    // ID del usuario que acepta
+	Timestamp time.Time `bson:"timestamp"`                     // Fecha del cambio
+}
+
+// AddressChangedEvent reemplaza la dirección de entrega
+type AddressChangedEvent struct {
+	Address   *Address  `bson:"address" validate:"required"` // Nueva dirección
+	UserId    string    `bson:"userId" validate:"required"`  // ID del usuario que cambia la dirección
+	Timestamp time.Time `bson:"timestamp"`                   // Fecha del cambio
+}
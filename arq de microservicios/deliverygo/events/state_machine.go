@@ -0,0 +1,122 @@
+// Máquina de estados del delivery.
+// Define en una sola tabla qué cambios de estado están permitidos, con qué evento se registran
+// y qué permiso necesita el usuario para ejecutarlos.
+package events
+
+import "deliverygo/security"
+
+// Permisos relativos al delivery que pueden requerir las transiciones.
+// Los permisos de usuario (como security.PermissionAdmin) se usan directamente.
+const (
+	PermissionOwner           = ""                 // Alcanza con ser el dueño del delivery (o admin)
+	PermissionAssignedCourier = "assigned_courier" // Solo el courier asignado al delivery (o admin)
+)
+
+// Transition define un cambio de estado permitido
+type Transition struct {
+	From       DeliveryStatus // Estado actual
+	Event      EventType      // Evento que registra el cambio
+	To         DeliveryStatus // Estado resultante
+	Permission string         // Permiso requerido para ejecutar la transición
+	Accepted   bool           // Requiere un courier asignado que haya aceptado la asignación
+}
+
+// transitions es la tabla con todas las transiciones válidas
+var transitions = []Transition{
+	{From: DeliveryStatusConfirmed, Event: CancelledDelivery, To: DeliveryStatusCancelled, Permission: PermissionOwner},
+	{From: DeliveryStatusOnTheGo, Event: CancelledDelivery, To: DeliveryStatusCancelled, Permission: security.PermissionAdmin},
+	{From: DeliveryStatusConfirmed, Event: SetOnTheGoDelivery, To: DeliveryStatusOnTheGo, Permission: PermissionAssignedCourier, Accepted: true},
+	{From: DeliveryStatusOnTheGo, Event: SetDeliveredDelivery, To: DeliveryStatusDelivered, Permission: PermissionAssignedCourier},
+	// La asignación de courier no cambia el estado, solo se permite antes de que salga a entregarse.
+	// El courier asignado puede aceptar la asignación o rechazarla, que la quita.
+	{From: DeliveryStatusConfirmed, Event: AssignCourier, To: DeliveryStatusConfirmed, Permission: security.PermissionAdmin},
+	{From: DeliveryStatusConfirmed, Event: UnassignCourier, To: DeliveryStatusConfirmed, Permission: PermissionAssignedCourier},
+	{From: DeliveryStatusConfirmed, Event: AcceptAssignment, To: DeliveryStatusConfirmed, Permission: PermissionAssignedCourier},
+	// El dueño puede corregir la dirección mientras el delivery no salió a entregarse
+	{From: DeliveryStatusConfirmed, Event: AddressChanged, To: DeliveryStatusConfirmed, Permission: PermissionOwner},
+}
+
+// FindTransition busca la transición que aplica el evento sobre el estado actual.
+// Si no existe retorna un *InvalidTransitionError.
+func FindTransition(from DeliveryStatus, event EventType) (*Transition, error) {
+	for i := range transitions {
+		if transitions[i].From == from && transitions[i].Event == event {
+			return &transitions[i], nil
+		}
+	}
+
+	return nil, &InvalidTransitionError{Current: from, Target: targetStatus(event), Event: event}
+}
+
+// FindTransitionTo busca la transición que lleva del estado actual al estado pedido.
+// Si no existe retorna un *InvalidTransitionError.
+func FindTransitionTo(from, to DeliveryStatus) (*Transition, error) {
+	for i := range transitions {
+		if transitions[i].From == from && transitions[i].To == to && transitions[i].ChangesStatus() {
+			return &transitions[i], nil
+		}
+	}
+
+	return nil, &InvalidTransitionError{Current: from, Target: to}
+}
+
+// AvailableTransitions retorna las transiciones que se pueden ejecutar desde un estado
+func AvailableTransitions(from DeliveryStatus) []Transition {
+	result := []Transition{}
+	for _, t := range transitions {
+		if t.From == from {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// ChangesStatus retorna true si la transición lleva el delivery a otro estado
+func (t Transition) ChangesStatus() bool {
+	return t.From != t.To
+}
+
+// ChangesStatus retorna true si el tipo de evento registra un cambio de estado del delivery
+func (t EventType) ChangesStatus() bool {
+	for _, transition := range transitions {
+		if transition.Event == t && transition.ChangesStatus() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal retorna true si desde el estado no hay más transiciones posibles
+func (ds DeliveryStatus) IsTerminal() bool {
+	return len(AvailableTransitions(ds)) == 0
+}
+
+// ActiveStatuses retorna los estados desde los que el delivery todavía puede cambiar
+func ActiveStatuses() []DeliveryStatus {
+	return filterStatuses(func(ds DeliveryStatus) bool { return !ds.IsTerminal() })
+}
+
+// FinishedStatuses retorna los estados terminales
+func FinishedStatuses() []DeliveryStatus {
+	return filterStatuses(DeliveryStatus.IsTerminal)
+}
+
+func filterStatuses(match func(DeliveryStatus) bool) []DeliveryStatus {
+	result := []DeliveryStatus{}
+	for _, ds := range []DeliveryStatus{DeliveryStatusConfirmed, DeliveryStatusOnTheGo, DeliveryStatusDelivered, DeliveryStatusCancelled} {
+		if match(ds) {
+			result = append(result, ds)
+		}
+	}
+	return result
+}
+
+// targetStatus busca el estado al que lleva un tipo de evento, para informar en los errores
+func targetStatus(event EventType) DeliveryStatus {
+	for _, t := range transitions {
+		if t.Event == event {
+			return t.To
+		}
+	}
+	return ""
+}
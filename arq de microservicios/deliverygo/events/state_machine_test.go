@@ -0,0 +1,148 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"deliverygo/security"
+)
+
+func TestFindTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       DeliveryStatus
+		event      EventType
+		to         DeliveryStatus
+		permission string
+		accepted   bool
+		invalid    bool
+	}{
+		{"cancelar confirmado", DeliveryStatusConfirmed, CancelledDelivery, DeliveryStatusCancelled, PermissionOwner, false, false},
+		{"cancelar en camino", DeliveryStatusOnTheGo, CancelledDelivery, DeliveryStatusCancelled, security.PermissionAdmin, false, false},
+		{"salir a entregar", DeliveryStatusConfirmed, SetOnTheGoDelivery, DeliveryStatusOnTheGo, PermissionAssignedCourier, true, false},
+		{"entregar", DeliveryStatusOnTheGo, SetDeliveredDelivery, DeliveryStatusDelivered, PermissionAssignedCourier, false, false},
+		{"asignar courier", DeliveryStatusConfirmed, AssignCourier, DeliveryStatusConfirmed, security.PermissionAdmin, false, false},
+		{"rechazar asignación", DeliveryStatusConfirmed, UnassignCourier, DeliveryStatusConfirmed, PermissionAssignedCourier, false, false},
+		{"aceptar asignación", DeliveryStatusConfirmed, AcceptAssignment, DeliveryStatusConfirmed, PermissionAssignedCourier, false, false},
+		{"cambiar dirección", DeliveryStatusConfirmed, AddressChanged, DeliveryStatusConfirmed, PermissionOwner, false, false},
+		{"entregar sin salir", DeliveryStatusConfirmed, SetDeliveredDelivery, "", "", false, true},
+		{"asignar en camino", DeliveryStatusOnTheGo, AssignCourier, "", "", false, true},
+		{"cambiar dirección en camino", DeliveryStatusOnTheGo, AddressChanged, "", "", false, true},
+		{"cancelar entregado", DeliveryStatusDelivered, CancelledDelivery, "", "", false, true},
+		{"salir cancelado", DeliveryStatusCancelled, SetOnTheGoDelivery, "", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition, err := FindTransition(tt.from, tt.event)
+			if tt.invalid {
+				var invalid *InvalidTransitionError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("err = %v, se esperaba *InvalidTransitionError", err)
+				}
+				if invalid.Current != tt.from || invalid.Event != tt.event {
+					t.Errorf("error = %+v, se esperaba Current %s y Event %s", invalid, tt.from, tt.event)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if transition.To != tt.to || transition.Permission != tt.permission || transition.Accepted != tt.accepted {
+				t.Errorf("transición = %+v, se esperaba To %s, Permission %q, Accepted %v", transition, tt.to, tt.permission, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestFindTransitionTo(t *testing.T) {
+	tests := []struct {
+		from    DeliveryStatus
+		to      DeliveryStatus
+		event   EventType
+		invalid bool
+	}{
+		{DeliveryStatusConfirmed, DeliveryStatusOnTheGo, SetOnTheGoDelivery, false},
+		{DeliveryStatusConfirmed, DeliveryStatusCancelled, CancelledDelivery, false},
+		{DeliveryStatusOnTheGo, DeliveryStatusDelivered, SetDeliveredDelivery, false},
+		{DeliveryStatusOnTheGo, DeliveryStatusCancelled, CancelledDelivery, false},
+		// Las transiciones que no cambian el estado no se buscan por estado destino
+		{DeliveryStatusConfirmed, DeliveryStatusConfirmed, "", true},
+		{DeliveryStatusConfirmed, DeliveryStatusDelivered, "", true},
+		{DeliveryStatusDelivered, DeliveryStatusCancelled, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			transition, err := FindTransitionTo(tt.from, tt.to)
+			if tt.invalid {
+				var invalid *InvalidTransitionError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("err = %v, se esperaba *InvalidTransitionError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if transition.Event != tt.event {
+				t.Errorf("Event = %s, se esperaba %s", transition.Event, tt.event)
+			}
+		})
+	}
+}
+
+func TestAvailableTransitions(t *testing.T) {
+	tests := []struct {
+		from   DeliveryStatus
+		events []EventType
+	}{
+		{DeliveryStatusConfirmed, []EventType{CancelledDelivery, SetOnTheGoDelivery, AssignCourier, UnassignCourier, AcceptAssignment, AddressChanged}},
+		{DeliveryStatusOnTheGo, []EventType{CancelledDelivery, SetDeliveredDelivery}},
+		{DeliveryStatusDelivered, []EventType{}},
+		{DeliveryStatusCancelled, []EventType{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from), func(t *testing.T) {
+			available := AvailableTransitions(tt.from)
+			if len(available) != len(tt.events) {
+				t.Fatalf("len = %d, se esperaba %d: %+v", len(available), len(tt.events), available)
+			}
+			for i, transition := range available {
+				if transition.From != tt.from || transition.Event != tt.events[i] {
+					t.Errorf("transición %d = %+v, se esperaba %s", i, transition, tt.events[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	tests := []struct {
+		status   DeliveryStatus
+		terminal bool
+	}{
+		{DeliveryStatusConfirmed, false},
+		{DeliveryStatusOnTheGo, false},
+		{DeliveryStatusDelivered, true},
+		{DeliveryStatusCancelled, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.IsTerminal(); got != tt.terminal {
+				t.Errorf("IsTerminal() = %v, se esperaba %v", got, tt.terminal)
+			}
+		})
+	}
+
+	finished := FinishedStatuses()
+	if len(finished) != 2 || finished[0] != DeliveryStatusDelivered || finished[1] != DeliveryStatusCancelled {
+		t.Errorf("FinishedStatuses() = %v", finished)
+	}
+	active := ActiveStatuses()
+	if len(active) != 2 || active[0] != DeliveryStatusConfirmed || active[1] != DeliveryStatusOnTheGo {
+		t.Errorf("ActiveStatuses() = %v", active)
+	}
+}
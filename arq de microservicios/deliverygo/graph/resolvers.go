@@ -0,0 +1,276 @@
+package graph
+
+import (
+	"context"
+
+	"deliverygo/broadcast"
+	"deliverygo/commands"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/security"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Cantidad de deliveries que devuelve la búsqueda por defecto y como máximo
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 500
+)
+
+// statusChange es lo que envía la suscripción por cada cambio de estado
+type statusChange struct {
+	DeliveryId string
+	Event      *events.Event
+}
+
+func resolveDelivery(p graphql.ResolveParams) (interface{}, error) {
+	return findVisibleDelivery(p, p.Args["deliveryId"].(string))
+}
+
+func resolveDeliveryByOrder(p graphql.ResolveParams) (interface{}, error) {
+	user := currentUser(p.Context)
+	delivery, err := projections.FindDeliveryByOrderId(p.Args["orderId"].(string), deps(p.Context)...)
+	if err != nil {
+		return nil, newError(notFound(err))
+	}
+	if !canView(delivery, user) {
+		return nil, newError(errs.Forbidden)
+	}
+	return delivery, nil
+}
+
+func resolveDeliveries(p graphql.ResolveParams) (interface{}, error) {
+	if !currentUser(p.Context).HasPermission(security.PermissionAdmin) {
+		return nil, newError(errs.Forbidden)
+	}
+
+	filter := projections.DeliveryFilter{
+		Limit: defaultDeliveriesLimit,
+	}
+	if status, ok := p.Args["status"].([]interface{}); ok {
+		for _, s := range status {
+			filter.Status = append(filter.Status, string(s.(events.DeliveryStatus)))
+		}
+	}
+	filter.UserId, _ = p.Args["userId"].(string)
+	filter.OrderId, _ = p.Args["orderId"].(string)
+	filter.CourierId, _ = p.Args["courierId"].(string)
+	if limit, ok := p.Args["limit"].(int); ok && limit > 0 {
+		filter.Limit = int64(min(limit, maxDeliveriesLimit))
+	}
+
+	page, err := projections.FindDeliveries(filter, deps(p.Context)...)
+	if err != nil {
+		return nil, newError(err)
+	}
+	return page.Deliveries, nil
+}
+
+func resolveEventHistory(p graphql.ResolveParams) (interface{}, error) {
+	delivery, err := findVisibleDelivery(p, p.Args["deliveryId"].(string))
+	if err != nil {
+		return nil, err
+	}
+	return events.FindDeliveryEventsByDeliveryId(delivery.DeliveryId, deps(p.Context)...)
+}
+
+func resolveDeliveryActions(p graphql.ResolveParams) (interface{}, error) {
+	delivery := p.Source.(*projections.DeliveryProjection)
+
+	actions := []events.DeliveryStatus{}
+	for _, t := range commands.AllowedTransitions(delivery, currentUser(p.Context)) {
+		if t.ChangesStatus() {
+			actions = append(actions, t.To)
+		}
+	}
+	return actions, nil
+}
+
+func resolveDeliveryEvents(p graphql.ResolveParams) (interface{}, error) {
+	delivery := p.Source.(*projections.DeliveryProjection)
+	return events.FindDeliveryEventsByDeliveryId(delivery.DeliveryId, deps(p.Context)...)
+}
+
+func resolveStatusChangeDelivery(p graphql.ResolveParams) (interface{}, error) {
+	change := p.Source.(*statusChange)
+	delivery, err := projections.FindByDeliveryId(change.DeliveryId, deps(p.Context)...)
+	if err != nil {
+		return nil, newError(notFound(err))
+	}
+	return delivery, nil
+}
+
+func resolveChangeStatus(p graphql.ResolveParams) (interface{}, error) {
+	status := p.Args["status"].(events.DeliveryStatus)
+	return commandResult(commands.ChangeStatus(p.Args["deliveryId"].(string), status, currentUser(p.Context), deps(p.Context)...))
+}
+
+func resolveChangeAddress(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["address"].(map[string]interface{})
+	address := &events.Address{}
+	address.Recipient, _ = input["recipient"].(string)
+	address.Street, _ = input["street"].(string)
+	address.City, _ = input["city"].(string)
+	address.PostalCode, _ = input["postalCode"].(string)
+	address.Country, _ = input["country"].(string)
+	address.Phone, _ = input["phone"].(string)
+	address.Instructions, _ = input["instructions"].(string)
+
+	return commandResult(commands.ChangeAddress(p.Args["deliveryId"].(string), address, currentUser(p.Context), deps(p.Context)...))
+}
+
+func resolveAssignCourier(p graphql.ResolveParams) (interface{}, error) {
+	if !currentUser(p.Context).HasPermission(security.PermissionAdmin) {
+		return nil, newError(errs.Forbidden)
+	}
+	courierId := p.Args["courierId"].(string)
+	return commandResult(commands.AssignCourier(p.Args["deliveryId"].(string), courierId, currentUser(p.Context), deps(p.Context)...))
+}
+
+func resolveUnassignCourier(p graphql.ResolveParams) (interface{}, error) {
+	return commandResult(commands.UnassignCourier(p.Args["deliveryId"].(string), currentUser(p.Context), deps(p.Context)...))
+}
+
+func resolveAcceptAssignment(p graphql.ResolveParams) (interface{}, error) {
+	return commandResult(commands.AcceptAssignment(p.Args["deliveryId"].(string), currentUser(p.Context), deps(p.Context)...))
+}
+
+func resolveRejectAssignment(p graphql.ResolveParams) (interface{}, error) {
+	return commandResult(commands.RejectAssignment(p.Args["deliveryId"].(string), currentUser(p.Context), deps(p.Context)...))
+}
+
+// subscribeStatusChanged se suscribe a los eventos de un delivery, o de todos si no se indica,
+// y envía solo los que cambian el estado. Termina cuando se cancela el contexto de la suscripción.
+func subscribeStatusChanged(p graphql.ResolveParams) (interface{}, error) {
+	user := currentUser(p.Context)
+
+	var messages <-chan *broadcast.Message
+	var unsubscribe func()
+	if deliveryId, ok := p.Args["deliveryId"].(string); ok && deliveryId != "" {
+		delivery, err := findVisibleDelivery(p, deliveryId)
+		if err != nil {
+			return nil, err
+		}
+		messages, unsubscribe = broadcast.Subscribe(delivery.DeliveryId)
+	} else {
+		if !user.HasPermission(security.PermissionAdmin) {
+			return nil, newError(errs.Forbidden)
+		}
+		messages, unsubscribe = broadcast.SubscribeAll()
+	}
+
+	result := make(chan interface{})
+	go func() {
+		defer close(result)
+		defer unsubscribe()
+
+		for {
+			select {
+			case m, open := <-messages:
+				if !open {
+					// El broadcaster desconectó al suscriptor por lento
+					log.Get(deps(p.Context)...).Info("Suscripción desconectada por lenta")
+					return
+				}
+				if m.Event == nil || !m.Event.Type.ChangesStatus() {
+					continue
+				}
+				select {
+				case result <- &statusChange{DeliveryId: m.DeliveryId, Event: m.Event}:
+				case <-p.Context.Done():
+					return
+				}
+			case <-p.Context.Done():
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+// findVisibleDelivery busca el delivery y verifica que el usuario sea el dueño o admin
+func findVisibleDelivery(p graphql.ResolveParams, deliveryId string) (*projections.DeliveryProjection, error) {
+	delivery, err := projections.FindByDeliveryId(deliveryId, deps(p.Context)...)
+	if err != nil {
+		return nil, newError(notFound(err))
+	}
+	if !canView(delivery, currentUser(p.Context)) {
+		return nil, newError(errs.Forbidden)
+	}
+	return delivery, nil
+}
+
+// canView retorna true si el usuario puede ver el delivery
+func canView(delivery *projections.DeliveryProjection, user *security.User) bool {
+	return user.ID == delivery.UserId || user.HasPermission(security.PermissionAdmin)
+}
+
+// commandResult adapta el resultado de un comando, el error se devuelve con su código en las extensiones
+func commandResult(delivery *projections.DeliveryProjection, err error) (interface{}, error) {
+	if err != nil {
+		return nil, newError(err)
+	}
+	return delivery, nil
+}
+
+// notFound traduce el error de Mongo cuando no existe el documento
+func notFound(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return errs.NotFound
+	}
+	return err
+}
+
+// gqlError agrega a los errores de los comandos el código http equivalente y,
+// para las transiciones inválidas, el estado actual del delivery
+type gqlError struct {
+	error
+}
+
+func newError(err error) error {
+	return &gqlError{err}
+}
+
+// Extensions implementa gqlerrors.ExtendedError
+func (e *gqlError) Extensions() map[string]interface{} {
+	switch err := e.error.(type) {
+	case *events.InvalidTransitionError:
+		return map[string]interface{}{"code": 409, "status": err.Current}
+	case errs.RestError:
+		return map[string]interface{}{"code": err.Status()}
+	case errs.Validation:
+		return map[string]interface{}{"code": 400}
+	}
+	return map[string]interface{}{"code": 500}
+}
+
+// contextKey claves de los valores que se guardan en el contexto de cada operación
+type contextKey string
+
+const (
+	userContextKey contextKey = "user"
+	depsContextKey contextKey = "deps"
+)
+
+// newContext arma el contexto de una operación con el usuario autenticado y el logger
+func newContext(parent context.Context, user *security.User, deps ...interface{}) context.Context {
+	ctx := context.WithValue(parent, userContextKey, user)
+	return context.WithValue(ctx, depsContextKey, deps)
+}
+
+// currentUser retorna el usuario autenticado de la operación
+func currentUser(ctx context.Context) *security.User {
+	user, _ := ctx.Value(userContextKey).(*security.User)
+	return user
+}
+
+// deps retorna las dependencias (logger) de la operación
+func deps(ctx context.Context) []interface{} {
+	deps, _ := ctx.Value(depsContextKey).([]interface{})
+	return deps
+}
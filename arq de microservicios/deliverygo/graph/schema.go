@@ -0,0 +1,255 @@
+// API GraphQL de deliveries. Expone las mismas consultas y comandos que la API REST sobre
+// las proyecciones y el event store, y una suscripción a los cambios de estado.
+package graph
+
+import (
+	"deliverygo/events"
+	"deliverygo/projections"
+
+	"github.com/graphql-go/graphql"
+)
+
+var deliveryStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "DeliveryStatus",
+	Values: graphql.EnumValueConfigMap{
+		string(events.DeliveryStatusConfirmed): &graphql.EnumValueConfig{Value: events.DeliveryStatusConfirmed},
+		string(events.DeliveryStatusCancelled): &graphql.EnumValueConfig{Value: events.DeliveryStatusCancelled},
+		string(events.DeliveryStatusOnTheGo):   &graphql.EnumValueConfig{Value: events.DeliveryStatusOnTheGo},
+		string(events.DeliveryStatusDelivered): &graphql.EnumValueConfig{Value: events.DeliveryStatusDelivered},
+	},
+})
+
+var eventTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "DeliveryEventType",
+	Values: graphql.EnumValueConfigMap{
+		string(events.ConfirmDelivery):      &graphql.EnumValueConfig{Value: events.ConfirmDelivery},
+		string(events.CancelledDelivery):    &graphql.EnumValueConfig{Value: events.CancelledDelivery},
+		string(events.SetOnTheGoDelivery):   &graphql.EnumValueConfig{Value: events.SetOnTheGoDelivery},
+		string(events.SetDeliveredDelivery): &graphql.EnumValueConfig{Value: events.SetDeliveredDelivery},
+		string(events.AssignCourier):        &graphql.EnumValueConfig{Value: events.AssignCourier},
+		string(events.UnassignCourier):      &graphql.EnumValueConfig{Value: events.UnassignCourier},
+		string(events.AcceptAssignment):     &graphql.EnumValueConfig{Value: events.AcceptAssignment},
+		string(events.AddressChanged):       &graphql.EnumValueConfig{Value: events.AddressChanged},
+	},
+})
+
+// Los campos de Address se resuelven por nombre contra events.Address
+var addressType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Address",
+	Fields: graphql.Fields{
+		"recipient":    &graphql.Field{Type: graphql.String},
+		"street":       &graphql.Field{Type: graphql.String},
+		"city":         &graphql.Field{Type: graphql.String},
+		"postalCode":   &graphql.Field{Type: graphql.String},
+		"country":      &graphql.Field{Type: graphql.String},
+		"phone":        &graphql.Field{Type: graphql.String},
+		"instructions": &graphql.Field{Type: graphql.String},
+	},
+})
+
+var addressInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "AddressInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"recipient":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"street":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"city":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"postalCode":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"country":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"phone":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"instructions": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+// DeliveryEvent se resuelve sobre *events.Event
+var deliveryEventType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DeliveryEvent",
+	Fields: graphql.Fields{
+		"version": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"type":    &graphql.Field{Type: graphql.NewNonNull(eventTypeEnum)},
+		"status": &graphql.Field{
+			Type: graphql.NewNonNull(deliveryStatusEnum),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*events.Event).DeliveryStatus, nil
+			},
+		},
+		"userId": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Usuario que generó el evento",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*events.Event).ActorId(), nil
+			},
+		},
+		"timestamp": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*events.Event).Timestamp(), nil
+			},
+		},
+		"created": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+// Delivery se resuelve sobre *projections.DeliveryProjection
+var deliveryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Delivery",
+	Fields: graphql.Fields{
+		"deliveryId": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"orderId":    &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"userId":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"status": &graphql.Field{
+			Type: graphql.NewNonNull(deliveryStatusEnum),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return events.DeliveryStatus(p.Source.(*projections.DeliveryProjection).Status), nil
+			},
+		},
+		"courierId":       &graphql.Field{Type: graphql.ID, Description: "userId del courier asignado"},
+		"courierAccepted": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"address":         &graphql.Field{Type: addressType},
+		"createdAt":       &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"lastModified":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"version":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Versión del stream de eventos"},
+		"actions": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(deliveryStatusEnum))),
+			Description: "Estados a los que el usuario puede llevar el delivery",
+			Resolve:     resolveDeliveryActions,
+		},
+		"events": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(deliveryEventType))),
+			Description: "Historial de eventos ordenado por versión",
+			Resolve:     resolveDeliveryEvents,
+		},
+	},
+})
+
+// DeliveryStatusChange se resuelve sobre *statusChange
+var deliveryStatusChangeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DeliveryStatusChange",
+	Fields: graphql.Fields{
+		"deliveryId": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"event":      &graphql.Field{Type: graphql.NewNonNull(deliveryEventType)},
+		"delivery": &graphql.Field{
+			Type:        deliveryType,
+			Description: "Estado del delivery después del cambio",
+			Resolve:     resolveStatusChangeDelivery,
+		},
+	},
+})
+
+var deliveryIdArgs = graphql.FieldConfigArgument{
+	"deliveryId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+}
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"delivery": &graphql.Field{
+			Type:        deliveryType,
+			Description: "Delivery por id, lo puede ver el dueño o un admin",
+			Args:        deliveryIdArgs,
+			Resolve:     resolveDelivery,
+		},
+		"deliveryByOrder": &graphql.Field{
+			Type:        deliveryType,
+			Description: "Delivery de una orden, lo puede ver el dueño o un admin",
+			Args: graphql.FieldConfigArgument{
+				"orderId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveDeliveryByOrder,
+		},
+		"deliveries": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(deliveryType))),
+			Description: "Búsqueda de deliveries, solo admins",
+			Args: graphql.FieldConfigArgument{
+				"status":    &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(deliveryStatusEnum))},
+				"userId":    &graphql.ArgumentConfig{Type: graphql.ID},
+				"orderId":   &graphql.ArgumentConfig{Type: graphql.ID},
+				"courierId": &graphql.ArgumentConfig{Type: graphql.ID},
+				"limit":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultDeliveriesLimit},
+			},
+			Resolve: resolveDeliveries,
+		},
+		"deliveryEvents": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(deliveryEventType))),
+			Description: "Historial de eventos de un delivery, lo puede ver el dueño o un admin",
+			Args:        deliveryIdArgs,
+			Resolve:     resolveEventHistory,
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"changeStatus": &graphql.Field{
+			Type: graphql.NewNonNull(deliveryType),
+			Args: graphql.FieldConfigArgument{
+				"deliveryId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"status":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(deliveryStatusEnum)},
+			},
+			Resolve: resolveChangeStatus,
+		},
+		"changeAddress": &graphql.Field{
+			Type: graphql.NewNonNull(deliveryType),
+			Args: graphql.FieldConfigArgument{
+				"deliveryId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"address":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(addressInput)},
+			},
+			Resolve: resolveChangeAddress,
+		},
+		"assignCourier": &graphql.Field{
+			Type: graphql.NewNonNull(deliveryType),
+			Args: graphql.FieldConfigArgument{
+				"deliveryId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"courierId":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveAssignCourier,
+		},
+		"unassignCourier": &graphql.Field{
+			Type:    graphql.NewNonNull(deliveryType),
+			Args:    deliveryIdArgs,
+			Resolve: resolveUnassignCourier,
+		},
+		"acceptAssignment": &graphql.Field{
+			Type:    graphql.NewNonNull(deliveryType),
+			Args:    deliveryIdArgs,
+			Resolve: resolveAcceptAssignment,
+		},
+		"rejectAssignment": &graphql.Field{
+			Type:    graphql.NewNonNull(deliveryType),
+			Args:    deliveryIdArgs,
+			Resolve: resolveRejectAssignment,
+		},
+	},
+})
+
+var subscriptionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Subscription",
+	Fields: graphql.Fields{
+		"deliveryStatusChanged": &graphql.Field{
+			Type:        graphql.NewNonNull(deliveryStatusChangeType),
+			Description: "Cambios de estado de un delivery (dueño o admin) o de todos los deliveries (solo admins)",
+			Args: graphql.FieldConfigArgument{
+				"deliveryId": &graphql.ArgumentConfig{Type: graphql.ID},
+			},
+			Subscribe: subscribeStatusChanged,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source, nil
+			},
+		},
+	},
+})
+
+// Schema es el esquema GraphQL del servicio
+var Schema graphql.Schema
+
+func init() {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:        queryType,
+		Mutation:     mutationType,
+		Subscription: subscriptionType,
+	})
+	if err != nil {
+		panic(err)
+	}
+	Schema = schema
+}
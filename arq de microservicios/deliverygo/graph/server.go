@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"deliverygo/security"
+	"deliverygo/tools/env"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"github.com/graphql-go/graphql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Request es el cuerpo de una operación GraphQL
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// Start inicia el servidor GraphQL en el puerto GqlPort.
+// Las queries y mutations se reciben por POST /graphql y las suscripciones por WebSocket
+// en la misma ruta, con el protocolo graphql-transport-ws.
+func Start() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			execute(w, r)
+		case http.MethodGet:
+			subscribe(w, r)
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		}
+	})
+
+	port := env.Get().GqlPort
+	log.Get().Info(fmt.Sprintf("GraphQL escuchando en el puerto %d", port))
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+		log.Get().Error(err)
+	}
+}
+
+// Ejecuta una query o mutation, el usuario se autentica con el header Authorization
+func execute(w http.ResponseWriter, r *http.Request) {
+	user, err := security.ValidateAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": errs.Unauthorized.Error()})
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	logger := newLogger(r.Header.Get(log.LOG_FIELD_CORRELATION_ID), user)
+	result := graphql.Do(graphql.Params{
+		Schema:         Schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        newContext(r.Context(), user, logger),
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeJSON responde body serializado como JSON
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// newLogger arma el logger de una operación, con el correlation id del request o uno nuevo
+func newLogger(correlationId string, user *security.User) *logrus.Entry {
+	if len(correlationId) == 0 {
+		correlationId = uuid.NewV4().String()
+	}
+
+	return log.Get().
+		WithField(log.LOG_FIELD_CONTROLLER, "GraphQL").
+		WithField(log.LOG_FIELD_CORRELATION_ID, correlationId).
+		WithField(log.LOG_FIELD_USER_ID, user.ID)
+}
@@ -0,0 +1,277 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"deliverygo/security"
+	"deliverygo/tools/env"
+	"deliverygo/tools/log"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/sirupsen/logrus"
+)
+
+// Subprotocolo de WebSocket para suscripciones GraphQL
+const subscriptionProtocol = "graphql-transport-ws"
+
+// Tipos de mensaje del protocolo graphql-transport-ws
+const (
+	messageConnectionInit = "connection_init"
+	messageConnectionAck  = "connection_ack"
+	messagePing           = "ping"
+	messagePong           = "pong"
+	messageSubscribe      = "subscribe"
+	messageNext           = "next"
+	messageError          = "error"
+	messageComplete       = "complete"
+)
+
+// Códigos de cierre del protocolo graphql-transport-ws
+const (
+	closeBadRequest          = 4400
+	closeForbidden           = 4403
+	closeInitTimeout         = 4408
+	closeSubscriberExists    = 4409
+	closeTooManyInitRequests = 4429
+)
+
+// Tiempos de la conexión: cuánto se espera el connection_init, cada cuánto se manda un ping,
+// cuánto se espera el pong y cuánto puede tardar una escritura
+const (
+	initTimeout  = 10 * time.Second
+	pingPeriod   = 30 * time.Second
+	pongWait     = 60 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{subscriptionProtocol},
+	// Mismos orígenes permitidos que el tablero de despacho
+	CheckOrigin: func(r *http.Request) bool {
+		return security.OriginAllowed(r, strings.Split(env.Get().BoardOrigins, ","))
+	},
+}
+
+// message es un mensaje del protocolo graphql-transport-ws
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// connection es una conexión de suscripciones con sus operaciones activas
+type connection struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	active  map[string]context.CancelFunc // id de la suscripción -> cancelación
+	user    *security.User
+	logger  *logrus.Entry
+}
+
+// Atiende las suscripciones por WebSocket.
+// El token se toma del header Authorization o, como los navegadores no pueden enviar headers
+// en el handshake, del campo Authorization del payload de connection_init.
+func subscribe(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade ya respondió el error al cliente
+		log.Get().Error(err)
+		return
+	}
+	defer conn.Close()
+
+	sc := &connection{
+		conn:   conn,
+		active: map[string]context.CancelFunc{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if !sc.init(r.Header.Get("Authorization"), r.Header.Get(log.LOG_FIELD_CORRELATION_ID)) {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go sc.keepAlive(ctx)
+
+	for {
+		var m message
+		if err := conn.ReadJSON(&m); err != nil {
+			return
+		}
+
+		switch m.Type {
+		case messagePing:
+			sc.write(message{Type: messagePong})
+		case messagePong:
+		case messageSubscribe:
+			if !sc.start(ctx, m) {
+				return
+			}
+		case messageComplete:
+			sc.stop(m.ID)
+		case messageConnectionInit:
+			sc.close(closeTooManyInitRequests, "Too many initialisation requests")
+			return
+		default:
+			sc.close(closeBadRequest, "Invalid message type")
+			return
+		}
+	}
+}
+
+// init espera el connection_init, autentica al usuario y responde connection_ack.
+// Si no se puede autenticar cierra la conexión y retorna false.
+func (sc *connection) init(header, correlationId string) bool {
+	sc.conn.SetReadDeadline(time.Now().Add(initTimeout))
+
+	var m message
+	if err := sc.conn.ReadJSON(&m); err != nil {
+		sc.close(closeInitTimeout, "Connection initialisation timeout")
+		return false
+	}
+	if m.Type != messageConnectionInit {
+		sc.close(closeBadRequest, "Expected connection_init")
+		return false
+	}
+
+	token := header
+	if len(token) == 0 {
+		payload := map[string]string{}
+		json.Unmarshal(m.Payload, &payload)
+		token = payload["Authorization"]
+	}
+	user, err := security.ValidateAuthorization(token)
+	if err != nil {
+		sc.close(closeForbidden, "Forbidden")
+		return false
+	}
+
+	sc.user = user
+	sc.logger = newLogger(correlationId, user)
+	return sc.write(message{Type: messageConnectionAck}) == nil
+}
+
+// start ejecuta la suscripción en una goroutine y envía cada resultado como next.
+// Retorna false si el id ya estaba en uso, en cuyo caso la conexión se cierra.
+func (sc *connection) start(parent context.Context, m message) bool {
+	var req Request
+	if err := json.Unmarshal(m.Payload, &req); err != nil || m.ID == "" {
+		sc.close(closeBadRequest, "Invalid subscribe message")
+		return false
+	}
+
+	sc.mu.Lock()
+	if _, exists := sc.active[m.ID]; exists {
+		sc.mu.Unlock()
+		sc.close(closeSubscriberExists, "Subscriber for "+m.ID+" already exists")
+		return false
+	}
+	ctx, cancel := context.WithCancel(parent)
+	sc.active[m.ID] = cancel
+	sc.mu.Unlock()
+
+	go func() {
+		defer sc.stop(m.ID)
+
+		results := graphql.Subscribe(graphql.Params{
+			Schema:         Schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        newContext(ctx, sc.user, sc.logger),
+		})
+
+		// El canal de resultados se lee hasta que se cierra para no bloquear al ejecutor
+		for result := range results {
+			if ctx.Err() != nil {
+				continue
+			}
+			if result.Data == nil && result.HasErrors() {
+				// Errores de validación o de la suscripción, terminan la operación
+				payload, _ := json.Marshal(result.Errors)
+				sc.write(message{ID: m.ID, Type: messageError, Payload: payload})
+				cancel()
+				continue
+			}
+			payload, _ := json.Marshal(result)
+			sc.write(message{ID: m.ID, Type: messageNext, Payload: payload})
+		}
+
+		if ctx.Err() == nil {
+			// La suscripción terminó del lado del servidor
+			sc.write(message{ID: m.ID, Type: messageComplete})
+		}
+	}()
+
+	return true
+}
+
+// stop cancela la suscripción indicada, si sigue activa
+func (sc *connection) stop(id string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if cancel, ok := sc.active[id]; ok {
+		cancel()
+		delete(sc.active, id)
+	}
+}
+
+// keepAlive envía pings hasta que se cierra la conexión
+func (sc *connection) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sc.writeMu.Lock()
+			err := sc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+			sc.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// write envía un mensaje, las escrituras de las distintas suscripciones se serializan
+func (sc *connection) write(m message) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	sc.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	err := sc.conn.WriteJSON(m)
+	if err != nil && sc.logger != nil {
+		sc.logger.Info("Suscripción desconectada: ", err)
+	}
+	return err
+}
+
+// close cierra la conexión con un código del protocolo
+func (sc *connection) close(code int, reason string) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	sc.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(writeTimeout),
+	)
+}
@@ -0,0 +1,141 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"deliverygo/tools/db"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var collection *mongo.Collection
+
+// Configura y devuelve la colección idempotencyKeys de MongoDB.
+func dbCollection(deps ...interface{}) (*mongo.Collection, error) {
+	if collection != nil {
+		return collection, nil
+	}
+
+	database, err := db.Get(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	col := database.Collection("idempotencyKeys")
+
+	_, err = col.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "key", Value: 1}}, // Las claves son por usuario
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.M{"created": 1}, // Mongo borra las claves vencidas
+				Options: options.Index().SetExpireAfterSeconds(int32(TTL.Seconds())),
+			},
+		},
+	)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	collection = col
+	return collection, nil
+}
+
+// Reserve registra la clave como pendiente. Si la clave ya existe no la modifica y
+// retorna el registro guardado con reserved en false, salvo que sea el mismo request y el
+// original no haya terminado en PendingTimeout, en ese caso se reserva de nuevo.
+func Reserve(userId, key, requestHash string, deps ...interface{}) (*Record, bool, error) {
+	now := time.Now()
+	record := &Record{
+		ID:           primitive.NewObjectID(),
+		UserId:       userId,
+		Key:          key,
+		RequestHash:  requestHash,
+		Status:       RecordStatusPending,
+		PendingUntil: now.Add(PendingTimeout),
+		Created:      now,
+	}
+	if err := record.Validate(); err != nil {
+		return nil, false, err
+	}
+
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err := col.InsertOne(context.Background(), record)
+		if err == nil {
+			return record, true, nil
+		}
+		if !db.IsUniqueKeyError(err) {
+			log.Get(deps...).Error(err)
+			return nil, false, err
+		}
+
+		existing := &Record{}
+		filter := bson.M{"userId": userId, "key": key}
+		if err := col.FindOne(context.Background(), filter).Decode(existing); err != nil {
+			log.Get(deps...).Error(err)
+			return nil, false, err
+		}
+		if attempt > 1 || !existing.abandoned(requestHash, now) {
+			return existing, false, nil
+		}
+
+		// Se borra la reserva abandonada y se vuelve a insertar con otro id, si el request original
+		// termina después no encuentra su registro. Si otro reintento la reserva primero, gana ese.
+		log.Get(deps...).Info("Reserva de idempotencia vencida, se reintenta la clave ", key)
+		if _, err := col.DeleteOne(context.Background(), bson.M{"_id": existing.ID, "status": RecordStatusPending}); err != nil {
+			log.Get(deps...).Error(err)
+			return nil, false, err
+		}
+	}
+}
+
+// Complete guarda la respuesta enviada para la clave
+func Complete(id primitive.ObjectID, status int, contentType string, headers map[string]string, body []byte, deps ...interface{}) error {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return err
+	}
+
+	_, err = col.UpdateByID(context.Background(), id, bson.M{
+		"$set": bson.M{
+			"status":         RecordStatusCompleted,
+			"responseStatus": status,
+			"contentType":    contentType,
+			"headers":        headers,
+			"responseBody":   body,
+		},
+	})
+	if err != nil {
+		log.Get(deps...).Error(err)
+	}
+	return err
+}
+
+// Release borra la clave para que el request se pueda reintentar, se usa cuando falló por un error del servidor
+func Release(id primitive.ObjectID, deps ...interface{}) error {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := col.DeleteOne(context.Background(), bson.M{"_id": id}); err != nil {
+		log.Get(deps...).Error(err)
+		return err
+	}
+	return nil
+}
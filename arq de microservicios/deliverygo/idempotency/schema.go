@@ -0,0 +1,51 @@
+// Claves de idempotencia de los requests que modifican deliveries.
+// Guarda por usuario y clave el hash del request y la respuesta enviada, así un reintento
+// con la misma clave recibe la respuesta original en lugar de aplicar el cambio dos veces.
+package idempotency
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TTL tiempo que se guarda una clave, después se puede volver a usar
+const TTL = 24 * time.Hour
+
+// PendingTimeout tiempo que puede tardar el request original. Si no terminó, por ejemplo porque
+// la instancia se cayó, un reintento con la misma clave y el mismo request lo vuelve a ejecutar.
+const PendingTimeout = time.Minute
+
+// RecordStatus estado de un request con clave de idempotencia
+type RecordStatus string
+
+const (
+	RecordStatusPending   RecordStatus = "pending"   // El request se está procesando
+	RecordStatusCompleted RecordStatus = "completed" // Se guardó la respuesta
+)
+
+// Record es una clave de idempotencia usada
+type Record struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	UserId         string             `bson:"userId" validate:"required"`
+	Key            string             `bson:"key" validate:"required,max=255"`
+	RequestHash    string             `bson:"requestHash" validate:"required"` // sha256 del método, la ruta y el body
+	Status         RecordStatus       `bson:"status" validate:"required"`
+	ResponseStatus int                `bson:"responseStatus,omitempty"`
+	ContentType    string             `bson:"contentType,omitempty"`
+	Headers        map[string]string  `bson:"headers,omitempty"` // Headers de la respuesta que se repiten, por ejemplo ETag
+	ResponseBody   []byte             `bson:"responseBody,omitempty"`
+	PendingUntil   time.Time          `bson:"pendingUntil"` // Si sigue pendiente después de esta fecha se puede reintentar
+	Created        time.Time          `bson:"created"`      // Se borra TTL después de esta fecha
+}
+
+// abandoned retorna true si el request original no terminó a tiempo y requestHash es el mismo request
+func (r *Record) abandoned(requestHash string, now time.Time) bool {
+	return r.Status == RecordStatusPending && r.RequestHash == requestHash && now.After(r.PendingUntil)
+}
+
+// Validate valida la estructura
+func (r *Record) Validate() error {
+	return validator.New().Struct(r)
+}
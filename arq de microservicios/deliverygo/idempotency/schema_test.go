@@ -0,0 +1,26 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordAbandoned(t *testing.T) {
+	now := time.Now()
+	record := &Record{RequestHash: "h1", Status: RecordStatusPending, PendingUntil: now.Add(-time.Second)}
+
+	if !record.abandoned("h1", now) {
+		t.Error("pendiente vencido con el mismo request debería estar abandonado")
+	}
+	if record.abandoned("h2", now) {
+		t.Error("otro request no puede tomar la clave")
+	}
+	if record.abandoned("h1", now.Add(-2*time.Second)) {
+		t.Error("pendiente sin vencer no está abandonado")
+	}
+
+	record.Status = RecordStatusCompleted
+	if record.abandoned("h1", now) {
+		t.Error("un request completado no está abandonado")
+	}
+}
@@ -0,0 +1,95 @@
+package locations
+
+import (
+	"context"
+
+	"deliverygo/tools/db"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var collection *mongo.Collection
+
+// Configura y devuelve la colección courierLocations de MongoDB.
+func dbCollection(deps ...interface{}) (*mongo.Collection, error) {
+	if collection != nil {
+		return collection, nil
+	}
+
+	database, err := db.Get(deps...)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	col := database.Collection("courierLocations")
+
+	_, err = col.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.M{"location": "2dsphere"}, // Consultas geográficas
+			},
+			{
+				Keys: bson.D{{Key: "deliveryId", Value: 1}, {Key: "timestamp", Value: -1}}, // Recorrido de un delivery
+			},
+			{
+				Keys:    bson.M{"created": 1}, // Mongo borra las posiciones viejas
+				Options: options.Index().SetExpireAfterSeconds(int32(Retention.Seconds())),
+			},
+		},
+	)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	collection = col
+	return collection, nil
+}
+
+// Insert guarda una posición
+func Insert(ping *Ping, deps ...interface{}) (*Ping, error) {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := col.InsertOne(context.Background(), ping); err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+
+	return ping, nil
+}
+
+// FindTrail retorna las últimas posiciones del delivery, de la más reciente a la más vieja
+func FindTrail(deliveryId string, limit int64, deps ...interface{}) ([]*Ping, error) {
+	col, err := dbCollection(deps...)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit)
+	cur, err := col.Find(context.Background(), bson.M{"deliveryId": deliveryId}, opts)
+	if err != nil {
+		log.Get(deps...).Error(err)
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := []*Ping{}
+	for cur.Next(context.Background()) {
+		ping := &Ping{}
+		if err := cur.Decode(ping); err != nil {
+			log.Get(deps...).Error(err)
+			return nil, err
+		}
+		result = append(result, ping)
+	}
+
+	return result, nil
+}
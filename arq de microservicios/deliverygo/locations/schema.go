@@ -0,0 +1,73 @@
+// Posiciones GPS que reportan los couriers mientras llevan un delivery.
+package locations
+
+import (
+	"time"
+
+	"deliverygo/tools/errs"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxClockSkew tolerancia para pings con fecha en el futuro por diferencias de reloj del dispositivo
+const maxClockSkew = time.Minute
+
+// Retention tiempo que se guardan las posiciones, después las borra el índice TTL
+const Retention = 30 * 24 * time.Hour
+
+// Point es un punto GeoJSON, las coordenadas van en orden [lng, lat]
+type Point struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// Ping es una posición reportada por el courier
+type Ping struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	DeliveryId string             `bson:"deliveryId" json:"deliveryId"`
+	CourierId  string             `bson:"courierId" json:"courierId"` // userId del courier
+	Location   Point              `bson:"location" json:"location"`
+	Lat        float64            `bson:"lat" json:"lat"`
+	Lng        float64            `bson:"lng" json:"lng"`
+	Accuracy   float64            `bson:"accuracy" json:"accuracy"`   // Precisión en metros
+	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"` // Fecha en la que el dispositivo tomó la posición
+	Created    time.Time          `bson:"created" json:"created"`     // Se borra Retention después de esta fecha
+}
+
+// NewPing arma el ping validando los datos, si no viene fecha se usa la actual
+func NewPing(deliveryId, courierId string, lat, lng, accuracy float64, timestamp time.Time) (*Ping, error) {
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	v := errs.NewValidation()
+	if lat < -90 || lat > 90 {
+		v.Add("lat", "Debe estar entre -90 y 90")
+	}
+	if lng < -180 || lng > 180 {
+		v.Add("lng", "Debe estar entre -180 y 180")
+	}
+	if accuracy < 0 {
+		v.Add("accuracy", "No puede ser negativa")
+	}
+	if timestamp.After(time.Now().Add(maxClockSkew)) {
+		v.Add("timestamp", "No puede ser futura")
+	}
+	if v.HasErrors() {
+		return nil, v
+	}
+
+	return &Ping{
+		DeliveryId: deliveryId,
+		CourierId:  courierId,
+		Location: Point{
+			Type:        "Point",
+			Coordinates: []float64{lng, lat},
+		},
+		Lat:       lat,
+		Lng:       lng,
+		Accuracy:  accuracy,
+		Timestamp: timestamp,
+		Created:   time.Now(),
+	}, nil
+}
@@ -1,14 +1,39 @@
 // Configura el logger, carga las variables de entorno, inicializa la base de datos y RabbitMQ
-// Inicia los diferentes servidores: REST y rabbit
+// Inicia los diferentes servidores: REST, GraphQL, gRPC y rabbit
+// Si se indica un subcomando (por ejemplo rebuild-projections) lo ejecuta en lugar de iniciar los servidores
 package main
 
 import (
-	routes "deliverygo/rest"
+	"os"
+
+	"deliverygo/events"
+	"deliverygo/graph"
+	"deliverygo/projections"
 	"deliverygo/rabbit/consume"
-	"deliverygo/tools/db"
+	emit "deliverygo/rabbit/emit"
+	routes "deliverygo/rest"
+	"deliverygo/rpc"
+	"deliverygo/tools/log"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// Sin los índices únicos se podrían crear deliveries duplicados, el servicio no inicia
+	if err := events.EnsureIndexes(); err != nil {
+		log.Get().Error(err)
+		os.Exit(1)
+	}
+	if err := projections.EnsureIndexes(); err != nil {
+		log.Get().Error(err)
+		os.Exit(1)
+	}
+
 	consume.Init()
+	emit.StartOutboxRelay()
+	go graph.Start()
+	go rpc.Start()
 	routes.Start()
 }
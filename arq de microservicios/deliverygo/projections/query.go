@@ -0,0 +1,188 @@
+// Búsqueda paginada de deliveries. La paginación es por cursor (keyset) sobre el campo de orden
+// y el _id, así el costo de cada página no depende de cuántas se leyeron antes.
+package projections
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"deliverygo/tools/errs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Campos por los que se puede ordenar el listado
+const (
+	SortByCreated      = "createdAt"
+	SortByLastModified = "lastModified"
+)
+
+// DeliveryFilter criterios de búsqueda de deliveries, los campos vacíos no filtran
+type DeliveryFilter struct {
+	Status       []string
+	UserId       string
+	OrderId      string
+	CourierId    string
+	CreatedFrom  *time.Time // Creados desde (inclusive)
+	CreatedTo    *time.Time // Creados hasta (exclusive)
+	ModifiedFrom *time.Time // Modificados desde (inclusive)
+	ModifiedTo   *time.Time // Modificados hasta (exclusive)
+	SortBy       string     // SortByCreated (por defecto) o SortByLastModified
+	Descending   bool
+	Limit        int64  // Cantidad máxima de resultados, 0 no limita
+	Cursor       string // NextCursor de la página anterior
+}
+
+// DeliveryPage es una página del listado
+type DeliveryPage struct {
+	Deliveries []*DeliveryProjection
+	NextCursor string // Vacío si no hay más resultados
+}
+
+// pageCursor es la posición del último documento de una página.
+// Guarda también el orden para rechazar cursores usados con otro orden.
+type pageCursor struct {
+	SortBy     string             `json:"s"`
+	Descending bool               `json:"d"`
+	Value      time.Time          `json:"v"`
+	ID         primitive.ObjectID `json:"id"`
+}
+
+// FindDeliveries busca una página de deliveries que cumplen el filtro
+func FindDeliveries(filter DeliveryFilter, ctx ...interface{}) (*DeliveryPage, error) {
+	if filter.SortBy == "" {
+		filter.SortBy = SortByCreated
+	}
+	if filter.SortBy != SortByCreated && filter.SortBy != SortByLastModified {
+		return nil, errs.NewValidation().Add("sort", "Orden inválido: "+filter.SortBy)
+	}
+
+	query := bson.M{}
+	if len(filter.Status) > 0 {
+		query["status"] = bson.M{"$in": filter.Status}
+	}
+	if filter.UserId != "" {
+		query["userId"] = filter.UserId
+	}
+	if filter.OrderId != "" {
+		query["orderId"] = filter.OrderId
+	}
+	if filter.CourierId != "" {
+		query["courierId"] = filter.CourierId
+	}
+	if dates := dateRange(filter.CreatedFrom, filter.CreatedTo); dates != nil {
+		query["createdAt"] = dates
+	}
+	if dates := dateRange(filter.ModifiedFrom, filter.ModifiedTo); dates != nil {
+		query["lastModified"] = dates
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil || cursor.SortBy != filter.SortBy || cursor.Descending != filter.Descending {
+			return nil, errs.NewValidation().Add("cursor", "Cursor inválido")
+		}
+		query["$and"] = []bson.M{afterCursor(cursor)}
+	}
+
+	col, err := dbCollection(ctx...)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := 1
+	if filter.Descending {
+		direction = -1
+	}
+	opts := options.Find().SetSort(bson.D{{Key: filter.SortBy, Value: direction}, {Key: "_id", Value: direction}})
+	if filter.Limit > 0 {
+		// Se pide uno más para saber si hay otra página
+		opts.SetLimit(filter.Limit + 1)
+	}
+
+	cur, err := col.Find(context.Background(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := &DeliveryPage{Deliveries: []*DeliveryProjection{}}
+	for cur.Next(context.Background()) {
+		dp := &DeliveryProjection{}
+		if err := cur.Decode(dp); err != nil {
+			return nil, err
+		}
+		result.Deliveries = append(result.Deliveries, dp)
+	}
+
+	if filter.Limit > 0 && int64(len(result.Deliveries)) > filter.Limit {
+		result.Deliveries = result.Deliveries[:filter.Limit]
+		last := result.Deliveries[filter.Limit-1]
+		result.NextCursor = encodeCursor(&pageCursor{
+			SortBy:     filter.SortBy,
+			Descending: filter.Descending,
+			Value:      sortValue(last, filter.SortBy),
+			ID:         last.ID,
+		})
+	}
+
+	return result, nil
+}
+
+// dateRange arma la condición de un rango de fechas, nil si no hay límites
+func dateRange(from, to *time.Time) bson.M {
+	if from == nil && to == nil {
+		return nil
+	}
+
+	result := bson.M{}
+	if from != nil {
+		result["$gte"] = *from
+	}
+	if to != nil {
+		result["$lt"] = *to
+	}
+	return result
+}
+
+// afterCursor arma la condición de los documentos que siguen al cursor en el orden pedido
+func afterCursor(cursor *pageCursor) bson.M {
+	op := "$gt"
+	if cursor.Descending {
+		op = "$lt"
+	}
+
+	return bson.M{"$or": []bson.M{
+		{cursor.SortBy: bson.M{op: cursor.Value}},
+		{cursor.SortBy: cursor.Value, "_id": bson.M{op: cursor.ID}},
+	}}
+}
+
+func sortValue(dp *DeliveryProjection, sortBy string) time.Time {
+	if sortBy == SortByLastModified {
+		return dp.LastModified
+	}
+	return dp.CreatedAt
+}
+
+func encodeCursor(cursor *pageCursor) string {
+	body, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func decodeCursor(value string) (*pageCursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := &pageCursor{}
+	if err := json.Unmarshal(body, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
@@ -0,0 +1,75 @@
+package projections
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"deliverygo/tools/errs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &pageCursor{
+		SortBy:     SortByLastModified,
+		Descending: true,
+		Value:      time.Date(2024, 5, 10, 13, 4, 5, 123000000, time.UTC),
+		ID:         primitive.NewObjectID(),
+	}
+
+	encoded := encodeCursor(cursor)
+	decoded, err := decodeCursor(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.SortBy != cursor.SortBy || decoded.Descending != cursor.Descending ||
+		!decoded.Value.Equal(cursor.Value) || decoded.ID != cursor.ID {
+		t.Errorf("decodeCursor(encodeCursor(c)) = %+v, esperaba %+v", decoded, cursor)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	// "bm8ganNvbg" es "no json" y "bnVs" es "nul", base64 válido con JSON inválido
+	for _, value := range []string{"no base64!", "bm8ganNvbg", "bnVs"} {
+		if _, err := decodeCursor(value); err == nil {
+			t.Errorf("decodeCursor(%q) debería fallar", value)
+		}
+	}
+}
+
+func TestAfterCursor(t *testing.T) {
+	value := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	id := primitive.NewObjectID()
+
+	for descending, op := range map[bool]string{false: "$gt", true: "$lt"} {
+		got := afterCursor(&pageCursor{SortBy: SortByCreated, Descending: descending, Value: value, ID: id})
+		want := bson.M{"$or": []bson.M{
+			{SortByCreated: bson.M{op: value}},
+			{SortByCreated: value, "_id": bson.M{op: id}},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("afterCursor(descending=%v) = %v, esperaba %v", descending, got, want)
+		}
+	}
+}
+
+func TestFindDeliveriesRejectsInvalidCursor(t *testing.T) {
+	sortedByCreated := encodeCursor(&pageCursor{SortBy: SortByCreated, Value: time.Now(), ID: primitive.NewObjectID()})
+
+	tests := []DeliveryFilter{
+		{SortBy: "status"},
+		{Cursor: "no base64!"},
+		// Cursor de otro orden
+		{SortBy: SortByLastModified, Cursor: sortedByCreated},
+		{Descending: true, Cursor: sortedByCreated},
+	}
+
+	for _, filter := range tests {
+		_, err := FindDeliveries(filter)
+		if _, ok := err.(errs.Validation); !ok {
+			t.Errorf("FindDeliveries(%+v): esperaba error de validación, obtuvo %v", filter, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Reconstrucción de la proyección a partir del event store.
+// Se usa para regenerar delivery_projection después de un bug o de un cambio de esquema.
+package projections
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"deliverygo/events"
+	"deliverygo/tools/db"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rebuildProgressEvery cada cuántos streams se informa el progreso
+const rebuildProgressEvery = 1000
+
+// rebuildCatchUpMargin se resta al inicio de la reconstrucción al buscar los eventos guardados
+// mientras tanto, cubre diferencias de reloj entre instancias
+const rebuildCatchUpMargin = time.Minute
+
+// ErrRebuildIncomplete hay streams que no se pudieron aplicar y no se reemplazó la proyección
+var ErrRebuildIncomplete = errs.NewRestError(500, "hay streams con error, la proyección no se reemplazó (usar -force para reemplazarla igual)")
+
+// RebuildResult resume la reconstrucción
+type RebuildResult struct {
+	Processed int               // Streams leídos
+	Applied   int               // Streams que se guardaron en la proyección
+	CaughtUp  int               // Streams que se volvieron a aplicar por eventos guardados durante la reconstrucción
+	Failed    map[string]string // deliveryId -> error de los streams que no se pudieron aplicar
+}
+
+// Rebuild aplica todos los streams del event store en una colección nueva y la reemplaza por
+// delivery_projection con un rename atómico. Si algún stream falla no se reemplaza, salvo con force.
+// Los eventos guardados durante la reconstrucción se vuelven a aplicar antes del rename en la
+// colección nueva y después del rename en la definitiva, sin pisar versiones más nuevas.
+func Rebuild(force bool, ctx ...interface{}) (*RebuildResult, error) {
+	logger := log.Get(ctx...)
+
+	database, err := db.Get(ctx...)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	tmpName := fmt.Sprintf("%s_rebuild_%d", collectionName, time.Now().Unix())
+	tmp := database.Collection(tmpName)
+	if err := createIndexes(tmp, ctx...); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	since := time.Now().Add(-rebuildCatchUpMargin)
+	result := &RebuildResult{Failed: map[string]string{}}
+	err = events.ForEachDeliveryStream(func(stream []*events.Event) error {
+		result.Processed++
+		deliveryId := stream[0].DeliveryId
+
+		projection, err := fromStream(deliveryId, stream)
+		if err == nil {
+			_, err = tmp.InsertOne(context.Background(), projection)
+		}
+		if err != nil {
+			logger.Error("No se pudo aplicar el stream ", deliveryId, ": ", err)
+			result.Failed[deliveryId] = err.Error()
+		} else {
+			result.Applied++
+		}
+
+		if result.Processed%rebuildProgressEvery == 0 {
+			logger.Info("Reconstrucción en progreso, streams procesados: ", result.Processed)
+		}
+		return nil
+	}, ctx...)
+	if err != nil {
+		logger.Error(err)
+		tmp.Drop(context.Background())
+		return result, err
+	}
+
+	// Eventos guardados en la proyección anterior mientras se recorría el event store
+	since, err = catchUp(tmp, since, result, ctx...)
+	if err != nil {
+		tmp.Drop(context.Background())
+		return result, err
+	}
+
+	if len(result.Failed) > 0 && !force {
+		logger.Error("Streams con error: ", len(result.Failed), ", no se reemplaza la proyección")
+		tmp.Drop(context.Background())
+		return result, ErrRebuildIncomplete
+	}
+
+	// renameCollection con dropTarget reemplaza la colección en una sola operación
+	cmd := bson.D{
+		{Key: "renameCollection", Value: database.Name() + "." + tmpName},
+		{Key: "to", Value: database.Name() + "." + collectionName},
+		{Key: "dropTarget", Value: true},
+	}
+	if err := database.Client().Database("admin").RunCommand(context.Background(), cmd).Err(); err != nil {
+		logger.Error(err)
+		tmp.Drop(context.Background())
+		return result, err
+	}
+
+	// Eventos guardados entre la pasada anterior y el rename, ya sobre la colección definitiva
+	col, err := dbCollection(ctx...)
+	if err != nil {
+		return result, err
+	}
+	if _, err := catchUp(col, since, result, ctx...); err != nil {
+		return result, err
+	}
+
+	logger.Info("Proyección reconstruida, streams aplicados: ", result.Applied, ", reaplicados: ", result.CaughtUp, ", con error: ", len(result.Failed))
+	return result, nil
+}
+
+// catchUp vuelve a aplicar en col los streams con eventos guardados desde since.
+// Retorna desde cuándo buscar en la próxima pasada.
+func catchUp(col *mongo.Collection, since time.Time, result *RebuildResult, ctx ...interface{}) (time.Time, error) {
+	next := time.Now().Add(-rebuildCatchUpMargin)
+
+	deliveryIds, err := events.FindDeliveryIdsSince(since, ctx...)
+	if err != nil {
+		return since, err
+	}
+
+	for _, deliveryId := range deliveryIds {
+		stream, err := events.FindDeliveryEventsByDeliveryId(deliveryId, ctx...)
+		if err != nil {
+			return since, err
+		}
+
+		projection, err := fromStream(deliveryId, stream)
+		if err == nil {
+			err = replaceIfNotNewer(col, projection, ctx...)
+		}
+		if err != nil {
+			log.Get(ctx...).Error("No se pudo aplicar el stream ", deliveryId, ": ", err)
+			result.Failed[deliveryId] = err.Error()
+			continue
+		}
+		delete(result.Failed, deliveryId)
+		result.CaughtUp++
+	}
+
+	return next, nil
+}
+
+// RebuildDelivery vuelve a generar la proyección de un solo delivery a partir de su stream.
+// Solo reemplaza el documento actual si no tiene una versión más nueva que la del stream leído.
+func RebuildDelivery(deliveryId string, ctx ...interface{}) (*RebuildResult, error) {
+	result := &RebuildResult{Failed: map[string]string{}}
+
+	stream, err := events.FindDeliveryEventsByDeliveryId(deliveryId, ctx...)
+	if err != nil {
+		return nil, err
+	}
+	result.Processed = 1
+
+	projection, err := fromStream(deliveryId, stream)
+	if err != nil {
+		result.Failed[deliveryId] = err.Error()
+		return result, nil
+	}
+
+	col, err := dbCollection(ctx...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := replaceIfNotNewer(col, projection, ctx...); err != nil {
+		result.Failed[deliveryId] = err.Error()
+		return result, nil
+	}
+
+	result.Applied = 1
+	return result, nil
+}
+
+// replaceIfNotNewer guarda la proyección salvo que col ya tenga una versión más nueva del delivery,
+// aplicada por Update mientras se leía el stream. Las proyecciones sin versión se reemplazan.
+func replaceIfNotNewer(col *mongo.Collection, projection *DeliveryProjection, ctx ...interface{}) error {
+	filter := bson.M{
+		"deliveryId": projection.DeliveryId,
+		"$or": []bson.M{
+			{"version": bson.M{"$lte": projection.Version}},
+			{"version": bson.M{"$exists": false}},
+		},
+	}
+	_, err := col.ReplaceOne(context.Background(), filter, projection, options.Replace().SetUpsert(true))
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	// El upsert choca con el documento existente cuando tiene una versión mayor
+	newer := col.FindOne(context.Background(), bson.M{
+		"deliveryId": projection.DeliveryId,
+		"version":    bson.M{"$gt": projection.Version},
+	})
+	if newer.Err() == nil {
+		log.Get(ctx...).Info("La proyección de ", projection.DeliveryId, " ya tiene una versión más nueva que ", projection.Version)
+		return nil
+	}
+	return err
+}
+
+// fromStream arma la proyección desde cero aplicando todos los eventos del stream
+func fromStream(deliveryId string, stream []*events.Event) (*DeliveryProjection, error) {
+	if len(stream) == 0 {
+		return nil, fmt.Errorf("no events found for deliveryId: %s", deliveryId)
+	}
+
+	projection := &DeliveryProjection{
+		DeliveryId: deliveryId,
+	}
+	for _, e := range stream {
+		projection = projection.update(e)
+	}
+
+	if err := projection.Validate(); err != nil {
+		return nil, err
+	}
+	return projection, nil
+}
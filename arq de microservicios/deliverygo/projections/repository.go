@@ -6,11 +6,22 @@ package projections
 import (
 	"context"
 	"deliverygo/tools/db"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const collectionName = "delivery_projection"
+
+// orderIdIndex nombre del índice único de orderId
+const orderIdIndex = "orderId_unique"
+
+// ErrDuplicateOrders hay más de un delivery por orden en la proyección y no se puede crear el índice único
+var ErrDuplicateOrders = errs.NewRestError(500, "hay más de un delivery por orden en delivery_projection, limpiar los duplicados con deliverygo dedupe-deliveries y regenerar la proyección con deliverygo rebuild-projections")
+
 var collection *mongo.Collection
 
 func dbCollection(ctx ...interface{}) (*mongo.Collection, error) {
@@ -24,29 +35,160 @@ func dbCollection(ctx ...interface{}) (*mongo.Collection, error) {
 		return nil, err
 	}
 
-	collection = database.Collection("delivery_projection")
+	col := database.Collection(collectionName)
+	if err := createIndexes(col, ctx...); err != nil {
+		return nil, err
+	}
+
+	collection = col
 	return collection, nil
 }
 
-func insert(dp *DeliveryProjection, ctx ...interface{}) (*DeliveryProjection, error) {
-	if err := dp.Validate(); err != nil {
+// EnsureIndexes crea los índices de delivery_projection. Se llama al iniciar el servicio para que
+// no arranque si hay deliveries duplicados que impiden crear el índice único de orderId.
+func EnsureIndexes(ctx ...interface{}) error {
+	_, err := dbCollection(ctx...)
+	return err
+}
+
+// createIndexes crea los índices de la proyección, se usa también al reconstruirla
+func createIndexes(col *mongo.Collection, ctx ...interface{}) error {
+	if err := createOrderIdIndex(col, ctx...); err != nil {
+		return err
+	}
+
+	_, err := col.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.M{"deliveryId": 1},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "courierId", Value: 1}, {Key: "status", Value: 1}}, // Deliveries de un courier
+			},
+			// Listados paginados (query.go), el _id desempata los documentos con la misma fecha.
+			// Los filtros por igualdad van antes que el campo de orden.
+			{
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "lastModified", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "courierId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "lastModified", Value: 1}, {Key: "_id", Value: 1}},
+			},
+		},
+	)
+	return err
+}
+
+// createOrderIdIndex crea el índice único de orderId, un delivery por orden.
+// Reemplaza al índice orderId_1 que no era único, Mongo no permite dos índices con las mismas claves.
+// Si hay duplicados anteriores no se puede crear: se restaura orderId_1 para las búsquedas y
+// retorna ErrDuplicateOrders.
+func createOrderIdIndex(col *mongo.Collection, ctx ...interface{}) error {
+	names, err := indexNames(col)
+	if err != nil {
+		return err
+	}
+	if names[orderIdIndex] {
+		return nil
+	}
+
+	if names["orderId_1"] {
+		log.Get(ctx...).Info("Migrando el índice orderId_1 de ", col.Name(), " a ", orderIdIndex)
+		if _, err := col.Indexes().DropOne(context.Background(), "orderId_1"); err != nil && !isIndexNotFound(err) {
+			return err
+		}
+	}
+
+	_, err = col.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"orderId": 1},
+		Options: options.Index().SetUnique(true).SetName(orderIdIndex),
+	})
+	if err == nil {
+		return nil
+	}
+
+	if names["orderId_1"] {
+		if _, restoreErr := col.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys: bson.M{"orderId": 1}, // Delivery de una orden
+		}); restoreErr != nil {
+			log.Get(ctx...).Error("No se pudo restaurar el índice orderId_1: ", restoreErr)
+		}
+	}
+	log.Get(ctx...).Error(err)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateOrders
+	}
+	return err
+}
+
+// indexNames retorna los nombres de los índices de la colección, ninguno si no existe
+func indexNames(col *mongo.Collection) (map[string]bool, error) {
+	names := map[string]bool{}
+	specs, err := col.Indexes().ListSpecifications(context.Background())
+	if err != nil {
+		if isIndexNotFound(err) {
+			return names, nil
+		}
 		return nil, err
 	}
+	for _, spec := range specs {
+		names[spec.Name] = true
+	}
+	return names, nil
+}
+
+// isIndexNotFound indica si el error es porque el índice o la colección no existen
+func isIndexNotFound(err error) bool {
+	cmdErr, ok := err.(mongo.CommandError)
+	return ok && (cmdErr.Code == 27 || cmdErr.Code == 26) // IndexNotFound, NamespaceNotFound
+}
+
+// saveIfVersion guarda la proyección solo si el documento sigue en la versión leída, o si no
+// existía y nadie lo creó mientras tanto. Retorna false si otro comando lo modificó antes.
+func saveIfVersion(dp *DeliveryProjection, readVersion int64, exists bool, ctx ...interface{}) (bool, error) {
+	if err := dp.Validate(); err != nil {
+		return false, err
+	}
 
 	col, err := dbCollection(ctx...)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	filter := bson.M{"deliveryId": dp.DeliveryId}
-	update := bson.M{"$set": dp}
-	options := options.Update().SetUpsert(true)
+	if !exists {
+		if _, err := col.InsertOne(context.Background(), dp); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
 
-	if _, err := col.UpdateOne(context.Background(), filter, update, options); err != nil {
-		return nil, err
+	filter := bson.M{"deliveryId": dp.DeliveryId, "version": readVersion}
+	if readVersion == 0 {
+		// Las proyecciones anteriores al versionado no tienen el campo
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
 	}
 
-	return dp, nil
+	result, err := col.UpdateOne(context.Background(), filter, bson.M{"$set": dp})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
 }
 
 func FindByDeliveryId(deliveryId string, ctx ...interface{}) (*DeliveryProjection, error) {
@@ -63,3 +205,77 @@ func FindByDeliveryId(deliveryId string, ctx ...interface{}) (*DeliveryProjectio
 
 	return dp, nil
 }
+
+// FindDeliveryByOrderId busca el delivery de una orden
+func FindDeliveryByOrderId(orderId string, ctx ...interface{}) (*DeliveryProjection, error) {
+	col, err := dbCollection(ctx...)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"orderId": orderId}
+	dp := &DeliveryProjection{}
+	if err := col.FindOne(context.Background(), filter).Decode(dp); err != nil {
+		return nil, err
+	}
+
+	return dp, nil
+}
+
+// FindByCourierId busca los deliveries asignados a un courier, opcionalmente filtrando por estados
+func FindByCourierId(courierId string, status []string, ctx ...interface{}) ([]*DeliveryProjection, error) {
+	col, err := dbCollection(ctx...)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"courierId": courierId}
+	if len(status) > 0 {
+		filter["status"] = bson.M{"$in": status}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cur, err := col.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := []*DeliveryProjection{}
+	for cur.Next(context.Background()) {
+		dp := &DeliveryProjection{}
+		if err := cur.Decode(dp); err != nil {
+			return nil, err
+		}
+		result = append(result, dp)
+	}
+
+	return result, nil
+}
+
+// FindByStatus busca los deliveries que están en alguno de los estados indicados, los más antiguos
+// primero y hasta limit resultados
+func FindByStatus(status []string, limit int64, ctx ...interface{}) ([]*DeliveryProjection, error) {
+	col, err := dbCollection(ctx...)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"status": bson.M{"$in": status}}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(limit)
+	cur, err := col.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	result := []*DeliveryProjection{}
+	for cur.Next(context.Background()) {
+		dp := &DeliveryProjection{}
+		if err := cur.Decode(dp); err != nil {
+			return nil, err
+		}
+		result = append(result, dp)
+	}
+
+	return result, nil
+}
@@ -3,43 +3,99 @@
 package projections
 
 import (
-	"github.com/virlop/ecommerce-ams/deliverygo/events"
+	"fmt"
+
+	"deliverygo/events"
+	"deliverygo/tools/log"
+
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// Update aplica los eventos sobre la proyección del delivery. Solo la guarda si sigue en la versión
+// que se leyó y los eventos son los siguientes del stream; si otro comando la actualizó antes, o le
+// falta algún evento, la regenera desde el stream para que la versión no vuelva atrás.
 func Update(deliveryId string, ev []*events.Event, ctx ...interface{}) error {
 	projection, err := FindByDeliveryId(deliveryId, ctx...)
 	if err != nil && err != mongo.ErrNoDocuments {
 		return err
 	}
 
-	if projection == nil {
+	exists := projection != nil
+	if !exists {
 		projection = &DeliveryProjection{
 			DeliveryId: deliveryId,
 		}
 	}
+	readVersion := projection.Version
+
+	if len(ev) > 0 && ev[0].Version > 0 && ev[0].Version != readVersion+1 {
+		return rebuildAfterConflict(deliveryId, readVersion, ctx...)
+	}
 
 	for _, e := range ev {
 		projection = projection.update(e)
 	}
 
-	if _, err := insert(projection, ctx...); err != nil {
+	saved, err := saveIfVersion(projection, readVersion, exists, ctx...)
+	if err != nil {
 		return err
 	}
+	if !saved {
+		return rebuildAfterConflict(deliveryId, readVersion, ctx...)
+	}
 
 	return nil
 }
 
+// rebuildAfterConflict regenera la proyección desde el stream cuando no está en la versión esperada
+func rebuildAfterConflict(deliveryId string, readVersion int64, ctx ...interface{}) error {
+	log.Get(ctx...).Info("La proyección de ", deliveryId, " no está en la versión ", readVersion, ", se regenera desde el stream")
+	result, err := RebuildDelivery(deliveryId, ctx...)
+	if err != nil {
+		return err
+	}
+	for _, reason := range result.Failed {
+		return fmt.Errorf("no se pudo regenerar la proyección de %s: %s", deliveryId, reason)
+	}
+	return nil
+}
+
 func (dp *DeliveryProjection) update(event *events.Event) *DeliveryProjection {
+	if dp.OrderId == "" {
+		dp.OrderId = event.OrderId
+	}
+
+	if event.Type == events.ConfirmDelivery {
+		dp.CreatedAt = event.Created
+		if event.ConfirmDelivery != nil && event.ConfirmDelivery.UserId != "" {
+			dp.UserId = event.ConfirmDelivery.UserId
+		}
+		if event.ConfirmDelivery != nil {
+			dp.Address = event.ConfirmDelivery.Address
+		}
+	}
 	switch event.Type {
-	case events.ConfirmDelivery:
-		dp.Status = "confirmed"
-	case events.CancelledDelivery:
-		dp.Status = "cancelled"
-	case events.SetOnTheGoDelivery:
-		dp.Status = "on_the_go"
-	case events.SetDeliveredDelivery:
-		dp.Status = "delivered"
+	case events.AssignCourier:
+		if event.AssignCourier != nil {
+			dp.CourierId = event.AssignCourier.CourierId
+		}
+		dp.CourierAccepted = false
+	case events.UnassignCourier:
+		dp.CourierId = ""
+		dp.CourierAccepted = false
+	case events.AcceptAssignment:
+		dp.CourierAccepted = true
+	case events.AddressChanged:
+		if event.AddressChanged != nil {
+			dp.Address = event.AddressChanged.Address
+		}
 	}
+	// El estado resultante ya viene validado por la máquina de estados de events
+	dp.Status = string(event.DeliveryStatus)
 	dp.LastModified = event.Created
+	// Los eventos anteriores al versionado no tienen versión
+	if event.Version > 0 {
+		dp.Version = event.Version
+	}
 	return dp
 }
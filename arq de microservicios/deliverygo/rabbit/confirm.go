@@ -0,0 +1,32 @@
+package rabbit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// WaitConfirm espera el confirm de RabbitMQ del mensaje deliveryTag en un canal con
+// publisher confirms. Descarta los confirms atrasados de mensajes anteriores que ya se
+// dieron por fallidos.
+func WaitConfirm(confirms chan amqp.Confirmation, deliveryTag uint64, timeout time.Duration) error {
+	expired := time.After(timeout)
+	for {
+		select {
+		case confirm, ok := <-confirms:
+			if !ok {
+				return fmt.Errorf("canal cerrado esperando confirmación")
+			}
+			if confirm.DeliveryTag < deliveryTag {
+				continue
+			}
+			if !confirm.Ack {
+				return fmt.Errorf("RabbitMQ rechazó el mensaje")
+			}
+			return nil
+		case <-expired:
+			return fmt.Errorf("sin confirmación de RabbitMQ")
+		}
+	}
+}
@@ -2,21 +2,84 @@ package consume
 
 import (
 	"encoding/json"
-	"time"
 
+	"deliverygo/commands"
 	"deliverygo/events"
 	"deliverygo/tools/env"
+	"deliverygo/tools/errs"
 	"deliverygo/tools/log"
 
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 )
 
 // Mensaje recibido para crear un Delivery
 type CreateDeliveryMessage struct {
-	CorrelationId string `json:"correlation_id"`
-	OrderId       string `json:"order_id"`
-	UserId        string `json:"user_id"`
+	CorrelationId string          `json:"correlation_id"`
+	OrderId       string          `json:"order_id"`
+	UserId        string          `json:"user_id"`
+	Address       *AddressMessage `json:"address"`
+}
+
+// Dirección de entrega recibida en el mensaje
+type AddressMessage struct {
+	Recipient    string `json:"recipient"`
+	Street       string `json:"street"`
+	City         string `json:"city"`
+	PostalCode   string `json:"postal_code"`
+	Country      string `json:"country"`
+	Phone        string `json:"phone"`
+	Instructions string `json:"instructions"`
+}
+
+// addressMessageFields nombres de los campos de la dirección en el mensaje, para los errores
+var addressMessageFields = events.AddressFields{
+	Recipient:    "recipient",
+	Street:       "street",
+	City:         "city",
+	PostalCode:   "postal_code",
+	Country:      "country",
+	Phone:        "phone",
+	Instructions: "instructions",
+}
+
+// toAddress convierte la dirección del mensaje en la dirección del delivery, nil si no vino
+func (a *AddressMessage) toAddress() *events.Address {
+	if a == nil {
+		return nil
+	}
+	return &events.Address{
+		Recipient:    a.Recipient,
+		Street:       a.Street,
+		City:         a.City,
+		PostalCode:   a.PostalCode,
+		Country:      a.Country,
+		Phone:        a.Phone,
+		Instructions: a.Instructions,
+	}
+}
+
+// validate valida los datos del mensaje
+func (m *CreateDeliveryMessage) validate() error {
+	v := errs.NewValidation()
+	if len(m.OrderId) == 0 {
+		v.Add("order_id", "Requerido")
+	}
+	if len(m.UserId) == 0 {
+		v.Add("user_id", "Requerido")
+	}
+	// La dirección se valida si viene, es requerida solo cuando el servicio de órdenes ya la envía
+	if m.Address != nil {
+		m.Address.toAddress().AddValidations(v, "address", addressMessageFields)
+	} else if env.Get().RequireAddress {
+		v.Add("address", "Requerido")
+	}
+
+	if v.HasErrors() {
+		return v
+	}
+	return nil
 }
 
 // consumeCreateDelivery escucha mensajes para la creación de un delivery
@@ -24,7 +87,7 @@ func consumeCreateDelivery() error {
 	logger := log.Get().
 		WithField(log.LOG_FIELD_CONTROLLER, "Rabbit").
 		WithField(log.LOG_FIELD_RABBIT_EXCHANGE, "delivery").
-		WithField(log.LOG_FIELD_RABBIT_QUEUE, "create_delivery").
+		WithField(log.LOG_FIELD_RABBIT_QUEUE, CreateDeliveryQueue).
 		WithField(log.LOG_FIELD_RABBIT_ACTION, "Consume")
 
 	// Conexión a RabbitMQ
@@ -60,18 +123,25 @@ func consumeCreateDelivery() error {
 
 	// Declarar la Cola
 	queue, err := chn.QueueDeclare(
-		"create_delivery", // Nombre de la cola
-		true,              // Durable
-		false,             // Auto-delete
-		false,             // Exclusivo
-		false,             // No-wait
-		nil,               // Args
+		CreateDeliveryQueue, // Nombre de la cola
+		true,                // Durable
+		false,               // Auto-delete
+		false,               // Exclusivo
+		false,               // No-wait
+		nil,                 // Args
 	)
 	if err != nil {
 		logger.Error("Error al declarar la cola: ", err)
 		return err
 	}
 
+	// Colas de reintentos y DLQ
+	err = declareRetryQueues(chn, queue.Name, CreateDeliveryDLQ, CreateDeliveryMaxAttempts)
+	if err != nil {
+		logger.Error("Error al declarar las colas de reintentos: ", err)
+		return err
+	}
+
 	// Vincular la Cola con el Exchange
 	err = chn.QueueBind(
 		queue.Name,     // Nombre de la cola
@@ -85,6 +155,13 @@ func consumeCreateDelivery() error {
 		return err
 	}
 
+	// Los mensajes que fallan se publican con confirms en el mismo canal
+	failures, err := newFailurePublisher(chn, queue.Name, CreateDeliveryDLQ, CreateDeliveryMaxAttempts)
+	if err != nil {
+		logger.Error("Error al activar confirms: ", err)
+		return err
+	}
+
 	// Consumir Mensajes
 	mgs, err := chn.Consume(
 		queue.Name, // Nombre de la cola
@@ -105,22 +182,7 @@ func consumeCreateDelivery() error {
 	// Procesar Mensajes
 	go func() {
 		for d := range mgs {
-			newMessage := &CreateDeliveryMessage{}
-			err := json.Unmarshal(d.Body, newMessage)
-			if err != nil {
-				logger.Error("Error al deserializar mensaje: ", err)
-				continue
-			}
-
-			// Procesar el mensaje
-			processCreateDelivery(newMessage, logger)
-
-			// Confirmar el mensaje (ACK)
-			if err := d.Ack(false); err != nil {
-				logger.Error("Error al confirmar mensaje: ", err)
-			} else {
-				logger.Info("Mensaje procesado correctamente: ", string(d.Body))
-			}
+			handleCreateDelivery(d, failures, logger)
 		}
 	}()
 
@@ -128,53 +190,74 @@ func consumeCreateDelivery() error {
 	return nil
 }
 
+// handleCreateDelivery procesa un mensaje y lo confirma. Si falla lo envía a reintentar o a la DLQ,
+// ningún mensaje se descarta sin quedar en alguna cola.
+func handleCreateDelivery(d amqp.Delivery, failures *failurePublisher, logger *logrus.Entry) {
+	l := logger.WithField(log.LOG_FIELD_CORRELATION_ID, d.CorrelationId)
+
+	newMessage := &CreateDeliveryMessage{}
+	err := json.Unmarshal(d.Body, newMessage)
+	if err != nil {
+		l.Error("Error al deserializar mensaje: ", err)
+		err = errs.NewValidation().Add("body", "Mensaje inválido: "+err.Error())
+	} else {
+		l = logger.WithField(log.LOG_FIELD_CORRELATION_ID, getCreateDeliveryCorrelationId(newMessage))
+		err = processCreateDelivery(newMessage, l)
+	}
+
+	if err == nil {
+		// Confirmar el mensaje (ACK)
+		if err := d.Ack(false); err != nil {
+			l.Error("Error al confirmar mensaje: ", err)
+		} else {
+			l.Info("Mensaje procesado correctamente: ", string(d.Body))
+		}
+		return
+	}
+
+	l.Error("Error al procesar mensaje: ", err)
+	deadLettered, pubErr := failures.fail(d, err)
+	switch {
+	case pubErr != nil:
+		l.Error("Error al enviar el mensaje a reintentar, se devuelve a la cola: ", pubErr)
+	case deadLettered:
+		l.Error("Mensaje enviado a ", CreateDeliveryDLQ, ": ", string(d.Body))
+	default:
+		l.Info("Mensaje enviado a reintentar, intento ", MessageAttempts(d.Headers)+1, " de ", CreateDeliveryMaxAttempts)
+	}
+}
+
 // processCreateDelivery maneja la lógica para crear un delivery
-func processCreateDelivery(newMessage *CreateDeliveryMessage, logger *log.Entry) {
+func processCreateDelivery(newMessage *CreateDeliveryMessage, deps ...interface{}) error {
+	logger := log.Get(deps...)
 	logger.Info("Procesando mensaje de creación de delivery")
 
-	// Crear el Delivery
-	delivery := &events.Delivery{
-		OrderId: newMessage.OrderId,
-		UserId:  newMessage.UserId,
-		Status:  "CONFIRMED",
-		Created: time.Now(),
+	if err := newMessage.validate(); err != nil {
+		return err
 	}
 
-	err := events.SaveDelivery(delivery)
+	// Crear el Delivery, si el mensaje se recibe de nuevo no se crea otro
+	delivery, created, err := commands.CreateDelivery(newMessage.OrderId, newMessage.UserId, newMessage.Address.toAddress(), newMessage.CorrelationId, deps...)
 	if err != nil {
 		logger.Error("Error al guardar el delivery: ", err)
-		return
+		return err
 	}
 
-	logger.Info("Delivery creado para la orden: ", newMessage.OrderId)
+	if !created {
+		logger.Info("Mensaje duplicado, la orden ", newMessage.OrderId, " ya tiene el delivery ", delivery.DeliveryId)
+		return nil
+	}
+
+	logger.Info("Delivery ", delivery.DeliveryId, " creado para la orden: ", newMessage.OrderId)
+	return nil
 }
 
-func ConsumeOrderCreatedEvents(ctx ...interface{}) {
-    msgs := rabbit.GetChannel(ctx...).Consume(
-        "order_payment_defined_queue", // Nombre de la cola
-        "",                            // Nombre del consumidor
-        true,                          // Auto-Ack
-        false,
-        false,
-        false,
-        nil,
-    )
-
-    for msg := range msgs {
-        eventData := struct {
-            DeliveryId string `json:"deliveryId"`
-            OrderId    string `json:"orderId"`
-            UserId     string `json:"userId"`
-        }{}
-
-        if err := json.Unmarshal(msg.Body, &eventData); err != nil {
-            log.Get(ctx...).Error("Failed to unmarshal message: ", err)
-            continue
-        }
-
-        event := NewConfirmDeliveryEvent(eventData.DeliveryId, eventData.OrderId, eventData.UserId)
-        if _, err := InsertDeliveryEvent(event, ctx...); err != nil {
-            log.Get(ctx...).Error("Failed to insert delivery event: ", err)
-        }
-    }
+func getCreateDeliveryCorrelationId(c *CreateDeliveryMessage) string {
+	value := c.CorrelationId
+
+	if len(value) == 0 {
+		value = uuid.NewV4().String()
+	}
+
+	return value
 }
@@ -0,0 +1,206 @@
+package consume
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"deliverygo/rabbit"
+	"deliverygo/tools/errs"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/streadway/amqp"
+)
+
+// Colas de create_delivery. Los mensajes que fallan se reintentan pasando por una cola de
+// espera y, cuando se agotan los intentos o el error no se puede resolver reintentando,
+// terminan en la dead letter queue.
+const (
+	CreateDeliveryQueue       = "create_delivery"
+	CreateDeliveryDLQ         = "create_delivery.dlq"
+	CreateDeliveryMaxAttempts = 5
+)
+
+// Headers que se agregan a los mensajes reintentados y a los que van a la DLQ
+const (
+	HeaderAttempts      = "x-attempts"       // Intentos fallidos
+	HeaderError         = "x-error"          // Error del último intento
+	HeaderFailedAt      = "x-failed-at"      // Fecha del último intento, RFC3339
+	HeaderOriginalQueue = "x-original-queue" // Cola de la que salió el mensaje
+)
+
+const (
+	retryBaseDelay   = 5 * time.Second  // Espera antes del primer reintento, se duplica en cada intento
+	retryConfirmWait = 10 * time.Second // Espera máxima del confirm de RabbitMQ
+)
+
+// retryQueueName es la cola de espera de un intento. Hay una cola por intento para que
+// todos los mensajes de una cola tengan el mismo TTL, RabbitMQ solo expira mensajes desde
+// el principio de la cola y uno con TTL largo demoraría a los que tienen TTL corto.
+func retryQueueName(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+// retryDelay calcula la espera exponencial antes del próximo intento
+func retryDelay(attempt int) time.Duration {
+	return retryBaseDelay * time.Duration(1<<(attempt-1))
+}
+
+// declareRetryQueues declara las colas de espera y la DLQ de una cola.
+// Al expirar el TTL RabbitMQ devuelve el mensaje a la cola original por el exchange por defecto.
+func declareRetryQueues(chn *amqp.Channel, queue, dlq string, maxAttempts int) error {
+	for attempt := 1; attempt < maxAttempts; attempt++ {
+		_, err := chn.QueueDeclare(
+			retryQueueName(queue, attempt), // Nombre de la cola
+			true,                           // Durable
+			false,                          // Auto-delete
+			false,                          // Exclusivo
+			false,                          // No-wait
+			amqp.Table{
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queue,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := chn.QueueDeclare(
+		dlq,   // Nombre de la cola
+		true,  // Durable
+		false, // Auto-delete
+		false, // Exclusivo
+		false, // No-wait
+		nil,   // Args
+	)
+	return err
+}
+
+// isPermanentError indica si el error se repite en cada intento, por ejemplo un mensaje inválido
+func isPermanentError(err error) bool {
+	switch e := err.(type) {
+	case errs.Validation:
+		return true
+	case errs.RestError:
+		return e.Status() < 500
+	}
+	return false
+}
+
+// MessageAttempts lee la cantidad de intentos fallidos de los headers del mensaje
+func MessageAttempts(headers amqp.Table) int {
+	switch value := headers[HeaderAttempts].(type) {
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	case string:
+		result, _ := strconv.Atoi(value)
+		return result
+	}
+	return 0
+}
+
+// failurePublisher mueve a la cola de espera o a la DLQ los mensajes que fallaron.
+// Publica con publisher confirms, el mensaje original solo se confirma una vez que
+// RabbitMQ tomó la copia.
+type failurePublisher struct {
+	channel     *amqp.Channel
+	confirms    chan amqp.Confirmation
+	deliveryTag uint64 // Número del último mensaje publicado en el canal
+	queue       string
+	dlq         string
+	maxAttempts int
+}
+
+func newFailurePublisher(chn *amqp.Channel, queue, dlq string, maxAttempts int) (*failurePublisher, error) {
+	if err := chn.Confirm(false); err != nil {
+		return nil, err
+	}
+
+	return &failurePublisher{
+		channel:     chn,
+		confirms:    chn.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		queue:       queue,
+		dlq:         dlq,
+		maxAttempts: maxAttempts,
+	}, nil
+}
+
+// fail publica el mensaje para reintentar o en la DLQ y confirma el original.
+// Si no se pudo publicar devuelve el mensaje a la cola para no perderlo.
+// Retorna true si el mensaje fue a la DLQ.
+func (p *failurePublisher) fail(d amqp.Delivery, cause error) (bool, error) {
+	attempts := MessageAttempts(d.Headers) + 1
+	target, delay, deadLetter := p.target(attempts, cause)
+
+	// La DLQ identifica los mensajes por MessageId
+	messageId := d.MessageId
+	if len(messageId) == 0 {
+		messageId = uuid.NewV4().String()
+	}
+
+	msg := amqp.Publishing{
+		Headers:       p.failureHeaders(d.Headers, attempts, cause),
+		ContentType:   d.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: d.CorrelationId,
+		MessageId:     messageId,
+		Timestamp:     d.Timestamp,
+		Body:          d.Body,
+	}
+	if !deadLetter {
+		msg.Expiration = strconv.FormatInt(delay.Milliseconds(), 10)
+	}
+
+	if err := p.publish(target, msg); err != nil {
+		if nackErr := d.Nack(false, true); nackErr != nil {
+			return false, nackErr
+		}
+		return false, err
+	}
+
+	return deadLetter, d.Ack(false)
+}
+
+// target retorna la cola a la que va el mensaje que falló por attempts-ésima vez y la espera
+// antes del próximo intento. Va a la DLQ si el error es permanente o se agotaron los intentos.
+func (p *failurePublisher) target(attempts int, cause error) (queue string, delay time.Duration, deadLetter bool) {
+	if isPermanentError(cause) || attempts >= p.maxAttempts {
+		return p.dlq, 0, true
+	}
+	return retryQueueName(p.queue, attempts), retryDelay(attempts), false
+}
+
+// failureHeaders copia los headers del mensaje y agrega los datos del intento fallido
+func (p *failurePublisher) failureHeaders(original amqp.Table, attempts int, cause error) amqp.Table {
+	headers := amqp.Table{}
+	for k, v := range original {
+		headers[k] = v
+	}
+	headers[HeaderAttempts] = int32(attempts)
+	headers[HeaderError] = cause.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderOriginalQueue] = p.queue
+	return headers
+}
+
+// publish publica en la cola por el exchange por defecto y espera el confirm
+func (p *failurePublisher) publish(queue string, msg amqp.Publishing) error {
+	err := p.channel.Publish(
+		"",    // Exchange por defecto, el routing key es el nombre de la cola
+		queue, // Routing key
+		false, // Mandatory
+		false, // Immediate
+		msg,
+	)
+	if err != nil {
+		return err
+	}
+	p.deliveryTag++
+
+	return rabbit.WaitConfirm(p.confirms, p.deliveryTag, retryConfirmWait)
+}
@@ -0,0 +1,117 @@
+package consume
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"deliverygo/events"
+	"deliverygo/tools/errs"
+
+	"github.com/streadway/amqp"
+)
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		if got := retryDelay(i + 1); got != w {
+			t.Errorf("retryDelay(%d) = %v, esperaba %v", i+1, got, w)
+		}
+	}
+
+	if got := retryQueueName(CreateDeliveryQueue, 2); got != "create_delivery.retry.2" {
+		t.Errorf("retryQueueName = %s", got)
+	}
+}
+
+func TestMessageAttempts(t *testing.T) {
+	tests := []struct {
+		headers amqp.Table
+		want    int
+	}{
+		{nil, 0},
+		{amqp.Table{}, 0},
+		{amqp.Table{HeaderAttempts: int32(3)}, 3},
+		{amqp.Table{HeaderAttempts: int64(4)}, 4},
+		{amqp.Table{HeaderAttempts: 2}, 2},
+		{amqp.Table{HeaderAttempts: "5"}, 5},
+		{amqp.Table{HeaderAttempts: "x"}, 0},
+		{amqp.Table{HeaderAttempts: 1.5}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := MessageAttempts(tt.headers); got != tt.want {
+			t.Errorf("MessageAttempts(%v) = %d, esperaba %d", tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestIsPermanentError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errs.NewValidation().Add("order_id", "Requerido"), true},
+		{errs.NotFound, true},
+		{events.ErrDuplicateDelivery, true},
+		{errs.Internal, false},
+		{errs.NewRestError(503, "Service unavailable"), false},
+		{errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isPermanentError(tt.err); got != tt.want {
+			t.Errorf("isPermanentError(%v) = %v, esperaba %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFailureTarget(t *testing.T) {
+	p := &failurePublisher{queue: CreateDeliveryQueue, dlq: CreateDeliveryDLQ, maxAttempts: CreateDeliveryMaxAttempts}
+	transient := errors.New("connection refused")
+
+	tests := []struct {
+		attempts   int
+		cause      error
+		queue      string
+		delay      time.Duration
+		deadLetter bool
+	}{
+		{1, transient, "create_delivery.retry.1", 5 * time.Second, false},
+		{2, transient, "create_delivery.retry.2", 10 * time.Second, false},
+		{3, transient, "create_delivery.retry.3", 20 * time.Second, false},
+		{4, transient, "create_delivery.retry.4", 40 * time.Second, false},
+		// El quinto intento fallido agota los reintentos
+		{5, transient, CreateDeliveryDLQ, 0, true},
+		{6, transient, CreateDeliveryDLQ, 0, true},
+		// Los errores permanentes van directo a la DLQ
+		{1, errs.NewValidation().Add("order_id", "Requerido"), CreateDeliveryDLQ, 0, true},
+	}
+
+	for _, tt := range tests {
+		queue, delay, deadLetter := p.target(tt.attempts, tt.cause)
+		if queue != tt.queue || delay != tt.delay || deadLetter != tt.deadLetter {
+			t.Errorf("target(%d, %v) = (%s, %v, %v), esperaba (%s, %v, %v)",
+				tt.attempts, tt.cause, queue, delay, deadLetter, tt.queue, tt.delay, tt.deadLetter)
+		}
+	}
+}
+
+func TestFailureHeaders(t *testing.T) {
+	p := &failurePublisher{queue: CreateDeliveryQueue, dlq: CreateDeliveryDLQ, maxAttempts: CreateDeliveryMaxAttempts}
+	original := amqp.Table{"x-custom": "a", HeaderAttempts: int32(2)}
+
+	headers := p.failureHeaders(original, MessageAttempts(original)+1, errors.New("timeout"))
+	if MessageAttempts(headers) != 3 {
+		t.Errorf("%s = %v, esperaba 3", HeaderAttempts, headers[HeaderAttempts])
+	}
+	if headers[HeaderError] != "timeout" || headers[HeaderOriginalQueue] != CreateDeliveryQueue || headers["x-custom"] != "a" {
+		t.Errorf("headers = %v", headers)
+	}
+	if _, err := time.Parse(time.RFC3339, headers[HeaderFailedAt].(string)); err != nil {
+		t.Errorf("%s: %v", HeaderFailedAt, err)
+	}
+	if original[HeaderAttempts] != int32(2) {
+		t.Error("no se deberían modificar los headers del mensaje original")
+	}
+}
@@ -0,0 +1,56 @@
+package rabbit
+
+import (
+	"time"
+
+	"deliverygo/events"
+)
+
+// DeliveryExchange es el exchange topic donde se publican los cambios de los deliveries.
+// El routing key es el tipo del evento, por ejemplo set_onthego_delivery.
+// Es distinto del exchange direct delivery donde se reciben los pedidos de creación.
+const DeliveryExchange = "delivery.events"
+
+// DeliveryEventSchemaVersion versión del formato de DeliveryEventPayload
+const DeliveryEventSchemaVersion = 1
+
+// DeliveryEventMessage es el mensaje que se publica por cada evento guardado
+type DeliveryEventMessage struct {
+	CorrelationId string               `json:"correlation_id" example:"123123"`
+	RoutingKey    string               `json:"routing_key" example:"set_onthego_delivery"`
+	Message       DeliveryEventPayload `json:"message"`
+}
+
+// DeliveryEventPayload son los datos del evento publicado
+type DeliveryEventPayload struct {
+	SchemaVersion int                   `json:"schemaVersion"`
+	EventId       string                `json:"eventId"`
+	DeliveryId    string                `json:"deliveryId"`
+	OrderId       string                `json:"orderId"`
+	Type          events.EventType      `json:"type"`
+	Status        events.DeliveryStatus `json:"status"`
+	Version       int64                 `json:"version"`             // Versión del evento en el stream del delivery
+	UserId        string                `json:"userId"`              // Usuario que generó el evento
+	ServiceId     string                `json:"serviceId,omitempty"` // Servicio que lo generó en nombre del usuario
+	Created       time.Time             `json:"created"`
+}
+
+// NewDeliveryEventMessage arma el mensaje a publicar para un evento
+func NewDeliveryEventMessage(event *events.Event, correlationId string) *DeliveryEventMessage {
+	return &DeliveryEventMessage{
+		CorrelationId: correlationId,
+		RoutingKey:    string(event.Type),
+		Message: DeliveryEventPayload{
+			SchemaVersion: DeliveryEventSchemaVersion,
+			EventId:       event.ID.Hex(),
+			DeliveryId:    event.DeliveryId,
+			OrderId:       event.OrderId,
+			Type:          event.Type,
+			Status:        event.DeliveryStatus,
+			Version:       event.Version,
+			UserId:        event.ActorId(),
+			ServiceId:     event.ServiceId,
+			Created:       event.Created,
+		},
+	}
+}
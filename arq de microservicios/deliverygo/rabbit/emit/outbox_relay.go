@@ -0,0 +1,169 @@
+package rabbit
+
+import (
+	"encoding/json"
+	"time"
+
+	"deliverygo/events"
+	"deliverygo/rabbit"
+	"deliverygo/tools/env"
+	"deliverygo/tools/log"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/streadway/amqp"
+)
+
+const (
+	outboxBatchSize    = 100              // Mensajes que se publican por vuelta
+	outboxPollInterval = time.Second      // Espera entre vueltas
+	outboxConfirmWait  = 10 * time.Second // Espera máxima del confirm de RabbitMQ
+	outboxMaxBackoff   = 5 * time.Minute  // Espera máxima entre reintentos de un mensaje
+	outboxClaimLease   = time.Minute      // Reserva de un mensaje, mayor a outboxConfirmWait
+)
+
+// StartOutboxRelay inicia el proceso que publica los mensajes pendientes del outbox
+// en el exchange delivery.events, usando publisher confirms. Si se pierde la conexión reconecta.
+// Cada mensaje se reserva antes de publicarlo, así varias réplicas no publican el mismo.
+func StartOutboxRelay() {
+	logger := log.Get().
+		WithField(log.LOG_FIELD_CONTROLLER, "Rabbit").
+		WithField(log.LOG_FIELD_RABBIT_EXCHANGE, DeliveryExchange).
+		WithField(log.LOG_FIELD_RABBIT_ACTION, "OutboxRelay")
+
+	go func() {
+		for {
+			err := relayOutbox(logger)
+			if err != nil {
+				logger.Error(err)
+			}
+			logger.Info("RabbitMQ outboxRelay conectando en 5 segundos.")
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+func relayOutbox(deps ...interface{}) error {
+	logger := log.Get(deps...)
+
+	conn, err := amqp.Dial(env.Get().RabbitURL)
+	if err != nil {
+		logger.Error("Error al conectar con RabbitMQ: ", err)
+		return err
+	}
+	defer conn.Close()
+
+	chn, err := conn.Channel()
+	if err != nil {
+		logger.Error("Error al crear el canal: ", err)
+		return err
+	}
+	defer chn.Close()
+
+	err = chn.ExchangeDeclare(
+		DeliveryExchange, // Nombre del exchange
+		"topic",          // Tipo
+		true,             // Durable
+		false,            // Auto-delete
+		false,            // Interno
+		false,            // No-wait
+		nil,              // Args
+	)
+	if err != nil {
+		logger.Error("Error al declarar el exchange: ", err)
+		return err
+	}
+
+	// Publisher confirms, RabbitMQ confirma cada mensaje una vez que lo tomó
+	if err := chn.Confirm(false); err != nil {
+		logger.Error("Error al activar confirms: ", err)
+		return err
+	}
+	publisher := &confirmedPublisher{
+		channel:  chn,
+		confirms: chn.NotifyPublish(make(chan amqp.Confirmation, 1)),
+	}
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	// Identifica las reservas de esta conexión
+	owner := uuid.NewV4().String()
+
+	logger.Info("RabbitMQ outboxRelay conectado")
+
+	for {
+		for i := 0; i < outboxBatchSize; i++ {
+			m, err := events.ClaimPendingOutbox(owner, outboxClaimLease, deps...)
+			if err != nil {
+				logger.Error(err)
+				break
+			}
+			if m == nil {
+				break
+			}
+			l := logger.WithField(log.LOG_FIELD_CORRELATION_ID, m.CorrelationId)
+
+			if err := publisher.publish(m); err != nil {
+				l.Error("Error al publicar evento del outbox: ", err)
+				events.MarkOutboxFailed(m, err, time.Now().Add(outboxBackoff(m.Attempts+1)), l)
+				if conn.IsClosed() {
+					return err
+				}
+				continue
+			}
+
+			events.MarkOutboxSent(m, l)
+		}
+
+		select {
+		case err := <-closed:
+			logger.Info("Closed connection: ", err)
+			return nil
+		case <-time.After(outboxPollInterval):
+		}
+	}
+}
+
+// outboxBackoff calcula la espera exponencial antes del próximo intento
+func outboxBackoff(attempts int) time.Duration {
+	wait := time.Second
+	for i := 1; i < attempts && wait < outboxMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > outboxMaxBackoff {
+		wait = outboxMaxBackoff
+	}
+	return wait
+}
+
+// confirmedPublisher publica de a un mensaje y espera el confirm de RabbitMQ
+type confirmedPublisher struct {
+	channel     *amqp.Channel
+	confirms    chan amqp.Confirmation
+	deliveryTag uint64 // Número del último mensaje publicado en el canal
+}
+
+func (p *confirmedPublisher) publish(m *events.OutboxMessage) error {
+	body, err := json.Marshal(NewDeliveryEventMessage(m.Event, m.CorrelationId))
+	if err != nil {
+		return err
+	}
+
+	err = p.channel.Publish(
+		DeliveryExchange,     // Nombre del exchange
+		string(m.Event.Type), // Routing key
+		false,                // Mandatory
+		false,                // Immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			DeliveryMode:  amqp.Persistent,
+			CorrelationId: m.CorrelationId,
+			MessageId:     m.Event.ID.Hex(),
+			Body:          body,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	p.deliveryTag++
+
+	return rabbit.WaitConfirm(p.confirms, p.deliveryTag, outboxConfirmWait)
+}
@@ -2,15 +2,19 @@ package rabbit
 
 import (
 	"deliverygo/rabbit"
+	"deliverygo/tools/log"
 	"encoding/json"
-	"log"
 
 	"github.com/streadway/amqp"
 )
 
 // PublishMessage publica un mensaje en un exchange
-func PublishMessage(exchange, routingKey string, body interface{}) error {
-	channel := rabbit.GetChannel()
+func PublishMessage(exchange, routingKey string, body interface{}, deps ...interface{}) error {
+	channel, err := rabbit.GetChannel()
+	if err != nil {
+		log.Get(deps...).Error("Error al conectar con RabbitMQ: ", err)
+		return err
+	}
 
 	// Serializa el mensaje en JSON
 	message, err := json.Marshal(body)
@@ -24,15 +28,16 @@ func PublishMessage(exchange, routingKey string, body interface{}) error {
 		false,      // Mandatory
 		false,      // Immediate
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        message,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         message,
 		},
 	)
 	if err != nil {
-		log.Printf("Error al publicar mensaje: %v", err)
+		log.Get(deps...).Error("Error al publicar mensaje: ", err)
 		return err
 	}
 
-	log.Printf("Mensaje publicado: %s", message)
+	log.Get(deps...).Info("Mensaje publicado: ", string(message))
 	return nil
 }
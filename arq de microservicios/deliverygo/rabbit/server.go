@@ -2,12 +2,16 @@ package rabbit
 
 import (
 	"log"
+	"sync"
+
+	"deliverygo/tools/env"
 
 	"github.com/streadway/amqp"
 )
 
 var connection *amqp.Connection
 var channel *amqp.Channel
+var mutex sync.Mutex
 
 // Init inicializa la conexión a RabbitMQ
 func Init(rabbitURL string) {
@@ -26,9 +30,39 @@ func Init(rabbitURL string) {
 	}
 }
 
-// GetChannel retorna el canal actual
-func GetChannel() *amqp.Channel {
-	return channel
+// GetChannel retorna el canal actual, si no hay conexión o se cerró la vuelve a abrir
+func GetChannel() (*amqp.Channel, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if connection == nil || connection.IsClosed() {
+		conn, err := amqp.Dial(env.Get().RabbitURL)
+		if err != nil {
+			return nil, err
+		}
+		connection = conn
+		channel = nil
+	}
+
+	if channel == nil {
+		chn, err := connection.Channel()
+		if err != nil {
+			return nil, err
+		}
+		channel = chn
+		// Si el canal se cierra por un error se descarta para abrir otro en el próximo uso
+		closed := chn.NotifyClose(make(chan *amqp.Error, 1))
+		go func() {
+			<-closed
+			mutex.Lock()
+			if channel == chn {
+				channel = nil
+			}
+			mutex.Unlock()
+		}()
+	}
+
+	return channel, nil
 }
 
 // Close cierra la conexión y el canal
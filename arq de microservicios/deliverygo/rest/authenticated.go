@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"deliverygo/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAuthenticatedUser obtiene el usuario logueado a partir del header Authorization
+func getAuthenticatedUser(c *gin.Context) (*security.User, error) {
+	return security.ValidateAuthorization(c.GetHeader("Authorization"))
+}
+
+// isAdmin retorna true si el usuario logueado tiene permisos de admin
+func isAdmin(c *gin.Context) bool {
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		return false
+	}
+
+	return user.HasPermission(security.PermissionAdmin)
+}
+
+// isCourier retorna true si el usuario logueado tiene permisos de courier
+func isCourier(c *gin.Context) bool {
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		return false
+	}
+
+	return user.HasPermission(security.PermissionCourier)
+}
+
+// canSeeDelivery retorna el usuario logueado y true si es el dueño del delivery o admin
+func canSeeDelivery(c *gin.Context, ownerId string) (*security.User, bool) {
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		return nil, false
+	}
+
+	return user, user.ID == ownerId || user.HasPermission(security.PermissionAdmin)
+}
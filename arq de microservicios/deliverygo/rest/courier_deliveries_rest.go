@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"deliverygo/commands"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/rest/server"
+	"deliverygo/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define las rutas para los couriers, requieren el permiso courier
+func init() {
+	server.Router().GET("/v1/courier/deliveries", server.ValidateAuthentication, validateCourier, listCourierDeliveries)
+	server.Router().POST("/v1/courier/deliveries/:deliveryId/accept", server.ValidateAuthentication, validateCourier, idempotent, acceptDelivery)
+	server.Router().POST("/v1/courier/deliveries/:deliveryId/reject", server.ValidateAuthentication, validateCourier, idempotent, rejectDelivery)
+	server.Router().POST("/v1/courier/deliveries/:deliveryId/start", server.ValidateAuthentication, validateCourier, idempotent, startDelivery)
+	server.Router().POST("/v1/courier/deliveries/:deliveryId/complete", server.ValidateAuthentication, validateCourier, idempotent, completeDelivery)
+	server.Router().POST("/v1/courier/deliveries/:deliveryId/location", server.ValidateAuthentication, validateCourier, idempotent, postLocation)
+}
+
+// Estructura para el cuerpo de la solicitud de posición del courier
+type LocationRequest struct {
+	Lat       *float64  `json:"lat" binding:"required"`
+	Lng       *float64  `json:"lng" binding:"required"`
+	Accuracy  float64   `json:"accuracy"`  // Precisión en metros
+	Timestamp time.Time `json:"timestamp"` // Opcional, por defecto la fecha actual
+}
+
+// validateCourier corta el request con 403 si el usuario no tiene el permiso courier
+func validateCourier(c *gin.Context) {
+	if !isCourier(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "El usuario no tiene permisos de courier"})
+		return
+	}
+}
+
+// Listar los deliveries asignados al courier logueado.
+// Por defecto solo los activos, con ?status=all también los terminados.
+func listCourierDeliveries(c *gin.Context) {
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	status := []string{}
+	if c.Query("status") != "all" {
+		for _, s := range events.ActiveStatuses() {
+			status = append(status, string(s))
+		}
+	}
+
+	ctx := server.GinCtx(c)
+	deliveries, err := projections.FindByCourierId(user.ID, status, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	result := []DeliveryResponse{}
+	for _, d := range deliveries {
+		result = append(result, newDeliveryResponse(d, user))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Aceptar la asignación de un delivery
+func acceptDelivery(c *gin.Context) {
+	runCourierCommand(c, commands.AcceptAssignment)
+}
+
+// Rechazar la asignación de un delivery, queda sin courier asignado
+func rejectDelivery(c *gin.Context) {
+	runCourierCommand(c, commands.RejectAssignment)
+}
+
+// Salir a entregar el delivery (on_the_go)
+func startDelivery(c *gin.Context) {
+	runCourierCommand(c, func(deliveryId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+		return commands.ChangeStatus(deliveryId, events.DeliveryStatusOnTheGo, user, deps...)
+	})
+}
+
+// Marcar el delivery como entregado
+func completeDelivery(c *gin.Context) {
+	runCourierCommand(c, func(deliveryId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error) {
+		return commands.ChangeStatus(deliveryId, events.DeliveryStatusDelivered, user, deps...)
+	})
+}
+
+// Registrar la posición actual del courier para un delivery en camino
+func postLocation(c *gin.Context) {
+	var req LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx := server.GinCtx(c)
+	ping, err := commands.RecordLocation(c.Param("deliveryId"), *req.Lat, *req.Lng, req.Accuracy, req.Timestamp, user, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ping)
+}
+
+type courierCommand func(deliveryId string, user *security.User, deps ...interface{}) (*projections.DeliveryProjection, error)
+
+// runCourierCommand ejecuta el comando sobre el delivery de la ruta con el usuario logueado
+func runCourierCommand(c *gin.Context, command courierCommand) {
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx, ok := commandCtx(c)
+	if !ok {
+		return
+	}
+	delivery, err := command(c.Param("deliveryId"), user, ctx...)
+	if err != nil {
+		abortWithCommandError(c, err)
+		return
+	}
+
+	respondDelivery(c, delivery, user)
+}
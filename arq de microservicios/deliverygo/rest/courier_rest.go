@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"net/http"
+
+	"deliverygo/commands"
+	"deliverygo/couriers"
+	"deliverygo/rest/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define las rutas de administración de couriers y asignación de deliveries
+func init() {
+	server.Router().POST("/v1/couriers", server.ValidateAuthentication, createCourier)
+	server.Router().GET("/v1/couriers", server.ValidateAuthentication, listCouriers)
+	server.Router().PUT("/v1/couriers/:userId/enabled", server.ValidateAuthentication, enableCourier)
+	server.Router().PUT("/v1/delivery/:deliveryId/courier", server.ValidateAuthentication, idempotent, assignCourier)
+	server.Router().DELETE("/v1/delivery/:deliveryId/courier", server.ValidateAuthentication, idempotent, unassignCourier)
+}
+
+// Estructura para el cuerpo de la solicitud de alta de courier
+type CreateCourierRequest struct {
+	UserId string `json:"userId" binding:"required"`
+	Name   string `json:"name" binding:"required"`
+	Phone  string `json:"phone"`
+}
+
+// Estructura para habilitar o deshabilitar un courier
+type EnableCourierRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Estructura para el cuerpo de la solicitud de asignación de courier
+type AssignCourierRequest struct {
+	CourierId string `json:"courierId" binding:"required"` // userId del courier
+}
+
+// Alta de un courier, solo admin
+func createCourier(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	var req CreateCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx := server.GinCtx(c)
+	courier, err := couriers.Insert(&couriers.Courier{
+		UserId:  req.UserId,
+		Name:    req.Name,
+		Phone:   req.Phone,
+		Enabled: true,
+	}, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, courier)
+}
+
+// Listar couriers, solo admin
+func listCouriers(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	ctx := server.GinCtx(c)
+	result, err := couriers.FindAll(ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Habilitar o deshabilitar un courier, solo admin
+func enableCourier(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	var req EnableCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx := server.GinCtx(c)
+	courier, err := couriers.SetEnabled(c.Param("userId"), req.Enabled, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, courier)
+}
+
+// Asignar un courier a un delivery, solo admin
+func assignCourier(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	var req AssignCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx, ok := commandCtx(c)
+	if !ok {
+		return
+	}
+	delivery, err := commands.AssignCourier(c.Param("deliveryId"), req.CourierId, user, ctx...)
+	if err != nil {
+		abortWithCommandError(c, err)
+		return
+	}
+
+	respondDelivery(c, delivery, user)
+}
+
+// Quitar el courier asignado a un delivery, solo admin
+func unassignCourier(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx, ok := commandCtx(c)
+	if !ok {
+		return
+	}
+	delivery, err := commands.UnassignCourier(c.Param("deliveryId"), user, ctx...)
+	if err != nil {
+		abortWithCommandError(c, err)
+		return
+	}
+
+	respondDelivery(c, delivery, user)
+}
@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/rest/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define las rutas del historial de eventos
+func init() {
+	server.Router().GET("/v1/delivery/:deliveryId/events", server.ValidateAuthentication, getDeliveryEvents)
+}
+
+// Estructura para la respuesta de un evento del historial
+type DeliveryEventResponse struct {
+	Version   int64                 `json:"version"`
+	Type      events.EventType      `json:"type"`
+	Status    events.DeliveryStatus `json:"status"`
+	UserId    string                `json:"userId"`              // Usuario que generó el evento
+	ServiceId string                `json:"serviceId,omitempty"` // Servicio que lo generó en nombre del usuario
+	Timestamp time.Time             `json:"timestamp"`
+	Created   time.Time             `json:"created"`
+}
+
+// Obtener el historial de eventos de un delivery, ordenado por versión.
+// Lo puede ver el dueño del delivery o un admin.
+func getDeliveryEvents(c *gin.Context) {
+	deliveryId := c.Param("deliveryId")
+	ctx := server.GinCtx(c)
+
+	stream, err := events.FindDeliveryEventsByDeliveryId(deliveryId, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	delivery, err := events.NewDelivery(stream)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery NO encontrado"})
+		return
+	}
+
+	// Los deliveries creados antes de guardar el dueño en el evento de confirmación lo tienen solo en la proyección
+	ownerId := delivery.UserId
+	if ownerId == "" {
+		projection, err := projections.FindByDeliveryId(deliveryId, ctx...)
+		if err != nil {
+			server.AbortWithError(c, err)
+			return
+		}
+		ownerId = projection.UserId
+	}
+
+	if _, ok := canSeeDelivery(c, ownerId); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Delivery NO corresponde al usuario autenticado"})
+		return
+	}
+
+	result := []DeliveryEventResponse{}
+	for _, e := range stream {
+		result = append(result, newDeliveryEventResponse(e))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func newDeliveryEventResponse(e *events.Event) DeliveryEventResponse {
+	return DeliveryEventResponse{
+		Version:   e.Version,
+		Type:      e.Type,
+		Status:    e.DeliveryStatus,
+		UserId:    e.ActorId(),
+		ServiceId: e.ServiceId,
+		Timestamp: e.Timestamp(),
+		Created:   e.Created,
+	}
+}
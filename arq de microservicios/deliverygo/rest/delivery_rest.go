@@ -1,21 +1,40 @@
-
 package rest
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	"deliverygo/commands"
+	"deliverygo/events"
 	"deliverygo/projections"
 	"deliverygo/rest/server"
 	"deliverygo/security"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cantidad de deliveries por página en los listados, por defecto y como máximo
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
 )
 
 // Define las rutas del servicio REST
 func init() {
-	server.Router().PUT("/v1/delivery/:deliveryId", server.ValidateAuthentication, updateDeliveryStatus)
-	server.Router().GET("/v1/delivery", server.ValidateAuthentication, listDeliveriesByStatus)
-	server.Router().GET("/v1/delivery/:orderId", server.ValidateAuthentication, getDeliveryByOrderId)
+	server.Router().PUT("/v1/delivery/:deliveryId", server.ValidateAuthentication, idempotent, updateDeliveryStatus)
+	server.Router().PUT("/v1/delivery/:deliveryId/address", server.ValidateAuthentication, idempotent, updateDeliveryAddress)
+	server.Router().POST("/v1/delivery/bulk/status", server.ValidateAuthentication, idempotent, bulkUpdateDeliveryStatus)
+	server.Router().GET("/v1/delivery", server.ValidateAuthentication, listDeliveries)
+	server.Router().GET("/v1/delivery/mine", server.ValidateAuthentication, listMyDeliveries)
+	server.Router().GET("/v1/delivery/order/:orderId", server.ValidateAuthentication, getDeliveryByOrderId)
+	// Ruta anterior de la búsqueda por orden, deprecada. gin exige que el parámetro se llame igual
+	// que en /v1/delivery/:deliveryId/events, pero el valor es un orderId.
+	server.Router().GET("/v1/delivery/:deliveryId", server.ValidateAuthentication, getDeliveryByLegacyOrderId)
 }
 
 // Estructura para el cuerpo de la solicitud de actualización de estado
@@ -25,14 +44,42 @@ type UpdateDeliveryRequest struct {
 
 // Estructura para la respuesta de un delivery
 type DeliveryResponse struct {
-	DeliveryId string `json:"deliveryId"`
-	OrderId    string `json:"orderId"`
-	Status     string `json:"status"`
-	Created    time.Time `json:"created"`
-	UserId     string `json:"userId"`
+	DeliveryId string                  `json:"deliveryId"`
+	OrderId    string                  `json:"orderId"`
+	Status     string                  `json:"status"`
+	Created    time.Time               `json:"created"`
+	UserId     string                  `json:"userId"`
+	CourierId  string                  `json:"courierId,omitempty"` // userId del courier asignado
+	Address    *events.Address         `json:"address,omitempty"`   // Dirección de entrega
+	Version    int64                   `json:"version"`             // Versión del stream, la misma del ETag
+	Actions    []events.DeliveryStatus `json:"actions"`             // Estados a los que el usuario puede llevar el delivery
 }
 
-// Actualizar estado de un delivery
+// newDeliveryResponse arma la respuesta con las acciones que el usuario puede ejecutar
+func newDeliveryResponse(delivery *projections.DeliveryProjection, user *security.User) DeliveryResponse {
+	actions := []events.DeliveryStatus{}
+	for _, t := range commands.AllowedTransitions(delivery, user) {
+		if t.ChangesStatus() {
+			actions = append(actions, t.To)
+		}
+	}
+
+	return DeliveryResponse{
+		DeliveryId: delivery.DeliveryId,
+		OrderId:    delivery.OrderId,
+		Status:     delivery.Status,
+		Created:    delivery.CreatedAt,
+		UserId:     delivery.UserId,
+		CourierId:  delivery.CourierId,
+		Address:    delivery.Address,
+		Version:    delivery.Version,
+		Actions:    actions,
+	}
+}
+
+// Actualizar estado de un delivery.
+// El cambio se registra como evento y se valida contra el estado actual, si la transición
+// no es válida se responde 409 con el estado en el que está el delivery.
 func updateDeliveryStatus(c *gin.Context) {
 	deliveryId := c.Param("deliveryId")
 	var req UpdateDeliveryRequest
@@ -42,49 +89,306 @@ func updateDeliveryStatus(c *gin.Context) {
 		return
 	}
 
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx, ok := commandCtx(c)
+	if !ok {
+		return
+	}
+	delivery, err := commands.ChangeStatus(deliveryId, events.DeliveryStatus(req.Status), user, ctx...)
+	if err != nil {
+		abortWithCommandError(c, err)
+		return
+	}
+
+	respondDelivery(c, delivery, user)
+}
+
+// Estructura para el cuerpo del cambio de estado masivo
+type BulkUpdateDeliveryRequest struct {
+	DeliveryIds []string `json:"deliveryIds" binding:"required"`
+	Status      string   `json:"status" binding:"required"`
+}
+
+// Estructura para el resultado de un delivery en el cambio de estado masivo
+type BulkUpdateResult struct {
+	DeliveryId string                `json:"deliveryId"`
+	Result     commands.BulkOutcome  `json:"result"`             // ok, conflict, not_found, forbidden o error
+	Status     events.DeliveryStatus `json:"status,omitempty"`   // Estado actual si la transición no era válida
+	Error      string                `json:"error,omitempty"`    // Motivo si no se pudo cambiar
+	Delivery   *DeliveryResponse     `json:"delivery,omitempty"` // Estado después del cambio
+}
+
+// Cambiar el estado de varios deliveries, solo admins.
+// Cada delivery se valida contra la máquina de estados por separado y se responde el
+// resultado de cada uno, los que no se pueden cambiar no impiden el cambio del resto.
+func bulkUpdateDeliveryStatus(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+	user, _ := getAuthenticatedUser(c)
+
+	var req BulkUpdateDeliveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
 	ctx := server.GinCtx(c)
-	delivery, err := projections.FindDeliveryById(deliveryId, ctx...)
+	results, err := commands.BulkChangeStatus(req.DeliveryIds, events.DeliveryStatus(req.Status), user, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	response := []BulkUpdateResult{}
+	for _, r := range results {
+		item := BulkUpdateResult{
+			DeliveryId: r.DeliveryId,
+			Result:     r.Outcome,
+			Status:     r.Current,
+		}
+		switch {
+		case r.Outcome == commands.BulkOutcomeError:
+			// El detalle queda en el log, no se expone
+			log.Get(ctx...).Error(r.DeliveryId, ": ", r.Err)
+			item.Error = errs.Internal.Error()
+		case r.Err != nil:
+			item.Error = r.Err.Error()
+		}
+		if r.Delivery != nil {
+			delivery := newDeliveryResponse(r.Delivery, user)
+			item.Delivery = &delivery
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": response})
+}
+
+// Cambiar la dirección de entrega.
+// Solo el dueño, y mientras el delivery esté confirmado, si ya salió a entregarse responde 409.
+func updateDeliveryAddress(c *gin.Context) {
+	deliveryId := c.Param("deliveryId")
+	var req events.Address
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	user, err := getAuthenticatedUser(c)
 	if err != nil {
 		server.AbortWithError(c, err)
 		return
 	}
 
-	if err := projections.UpdateDeliveryStatus(deliveryId, req.Status, ctx...); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	ctx, ok := commandCtx(c)
+	if !ok {
+		return
+	}
+	delivery, err := commands.ChangeAddress(deliveryId, &req, user, ctx...)
+	if err != nil {
+		abortWithCommandError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, DeliveryResponse{
-		DeliveryId: deliveryId,
-		OrderId:    delivery.OrderId,
-		Status:     req.Status,
-		Created:    time.Now(),
-		UserId:     delivery.UserId,
-	})
+	respondDelivery(c, delivery, user)
 }
 
-// Listar deliveries por estado
-func listDeliveriesByStatus(c *gin.Context) {
-	status := c.Query("status")
+// abortWithCommandError responde el error de un comando, las transiciones inválidas
+// se responden con 409 y el estado actual del delivery
+func abortWithCommandError(c *gin.Context, err error) {
+	if transitionErr, ok := err.(*events.InvalidTransitionError); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": transitionErr.Error(), "status": transitionErr.Current})
+		return
+	}
+	server.AbortWithError(c, err)
+}
 
-	if !security.IsAdmin(c) {
+// Listar deliveries, solo admins.
+// Filtros: status (se puede repetir o separar por comas), userId, orderId, courierId,
+// createdFrom/createdTo y modifiedFrom/modifiedTo (RFC 3339).
+// Orden: sort=createdAt|lastModified, con "-" adelante para orden descendente.
+// Paginación: limit y cursor, con el nextCursor de la respuesta anterior.
+func listDeliveries(c *gin.Context) {
+	if !isAdmin(c) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
 		return
 	}
+	user, _ := getAuthenticatedUser(c)
+
+	filter, err := parseDeliveryFilter(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	ctx := server.GinCtx(c)
+	page, err := projections.FindDeliveries(filter, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newDeliveryPageResponse(page, user))
+}
+
+// Listar los deliveries del usuario logueado, del más reciente al más viejo.
+// ?status=active solo los que están en curso, ?status=finished solo los terminados.
+// Paginación con limit y cursor igual que en el listado de admins.
+func listMyDeliveries(c *gin.Context) {
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	filter := projections.DeliveryFilter{
+		UserId:     user.ID,
+		Cursor:     c.Query("cursor"),
+		Descending: true,
+		Limit:      defaultPageLimit,
+	}
+	v := errs.NewValidation()
+
+	var status []events.DeliveryStatus
+	switch c.Query("status") {
+	case "":
+	case "active":
+		status = events.ActiveStatuses()
+	case "finished":
+		status = events.FinishedStatuses()
+	default:
+		v.Add("status", "invalid status: "+c.Query("status"))
+	}
+	for _, s := range status {
+		filter.Status = append(filter.Status, string(s))
+	}
+	filter.Limit = parseLimitParam(c, v)
+
+	if v.HasErrors() {
+		server.AbortWithError(c, v)
+		return
+	}
 
 	ctx := server.GinCtx(c)
-	deliveries, err := projections.FindDeliveriesByStatus(status, ctx...)
+	page, err := projections.FindDeliveries(filter, ctx...)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Deliveries NO encontrados"})
+		server.AbortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, deliveries)
+	c.JSON(http.StatusOK, newDeliveryPageResponse(page, user))
+}
+
+// Estructura para la respuesta de un listado paginado
+type DeliveryPageResponse struct {
+	Deliveries []DeliveryResponse `json:"deliveries"`
+	NextCursor string             `json:"nextCursor,omitempty"` // Vacío si no hay más resultados
+}
+
+func newDeliveryPageResponse(page *projections.DeliveryPage, user *security.User) DeliveryPageResponse {
+	result := DeliveryPageResponse{
+		Deliveries: []DeliveryResponse{},
+		NextCursor: page.NextCursor,
+	}
+	for _, d := range page.Deliveries {
+		result.Deliveries = append(result.Deliveries, newDeliveryResponse(d, user))
+	}
+	return result
+}
+
+// parseDeliveryFilter arma el filtro del listado a partir de los parámetros del request
+func parseDeliveryFilter(c *gin.Context) (projections.DeliveryFilter, error) {
+	filter := projections.DeliveryFilter{
+		UserId:    c.Query("userId"),
+		OrderId:   c.Query("orderId"),
+		CourierId: c.Query("courierId"),
+		Cursor:    c.Query("cursor"),
+	}
+	v := errs.NewValidation()
+
+	for _, value := range c.QueryArray("status") {
+		for _, status := range strings.Split(value, ",") {
+			if !events.DeliveryStatus(status).IsValid() {
+				v.Add("status", "invalid status: "+status)
+			}
+			filter.Status = append(filter.Status, status)
+		}
+	}
+
+	filter.CreatedFrom = parseTimeParam(c, "createdFrom", v)
+	filter.CreatedTo = parseTimeParam(c, "createdTo", v)
+	filter.ModifiedFrom = parseTimeParam(c, "modifiedFrom", v)
+	filter.ModifiedTo = parseTimeParam(c, "modifiedTo", v)
+
+	if sort := c.Query("sort"); sort != "" {
+		filter.Descending = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+		if filter.SortBy != projections.SortByCreated && filter.SortBy != projections.SortByLastModified {
+			v.Add("sort", "invalid sort: "+sort)
+		}
+	}
+
+	filter.Limit = parseLimitParam(c, v)
+
+	if v.HasErrors() {
+		return filter, v
+	}
+	return filter, nil
+}
+
+// parseLimitParam lee el tamaño de página, defaultPageLimit si no viene
+func parseLimitParam(c *gin.Context, v errs.Validation) int64 {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultPageLimit
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		v.Add("limit", "invalid limit: "+value)
+		return defaultPageLimit
+	}
+	return min(limit, maxPageLimit)
+}
+
+// parseTimeParam lee un parámetro de fecha en formato RFC 3339, nil si no viene
+func parseTimeParam(c *gin.Context, name string, v errs.Validation) *time.Time {
+	value := c.Query(name)
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		v.Add(name, "invalid date: "+value)
+		return nil
+	}
+	return &t
 }
 
-// Obtener detalles de un delivery por orderId
+// Obtener detalles de un delivery por orderId, solo el dueño o un admin
 func getDeliveryByOrderId(c *gin.Context) {
-	orderId := c.Param("orderId")
+	respondDeliveryByOrderId(c, c.Param("orderId"))
+}
+
+// getDeliveryByLegacyOrderId atiende GET /v1/delivery/:orderId, que usan los clientes anteriores a
+// /v1/delivery/order/:orderId. Responde lo mismo e informa la ruta nueva en los headers.
+func getDeliveryByLegacyOrderId(c *gin.Context) {
+	orderId := c.Param("deliveryId")
+	c.Header("Deprecation", "true")
+	c.Header("Link", "</v1/delivery/order/"+url.PathEscape(orderId)+`>; rel="successor-version"`)
+	respondDeliveryByOrderId(c, orderId)
+}
+
+func respondDeliveryByOrderId(c *gin.Context, orderId string) {
 	ctx := server.GinCtx(c)
 	delivery, err := projections.FindDeliveryByOrderId(orderId, ctx...)
 	if err != nil {
@@ -92,17 +396,14 @@ func getDeliveryByOrderId(c *gin.Context) {
 		return
 	}
 
-	user, err := security.GetAuthenticatedUser(c)
-	if err != nil || user.ID != delivery.UserId {
+	user, ok := canSeeDelivery(c, delivery.UserId)
+	if !ok {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Delivery NO corresponde al usuario autenticado"})
 		return
 	}
 
-	c.JSON(http.StatusOK, DeliveryResponse{
-		DeliveryId: delivery.DeliveryId,
-		OrderId:    orderId,
-		Status:     delivery.Status,
-		Created:    delivery.Created,
-		UserId:     delivery.UserId,
-	})
+	if notModified(c, delivery) {
+		return
+	}
+	respondDelivery(c, delivery, user)
 }
@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"deliverygo/broadcast"
+	"deliverygo/events"
+	"deliverygo/rest/server"
+	"deliverygo/tools/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat cada cuánto se envía un comentario para mantener viva la conexión
+const streamHeartbeat = 15 * time.Second
+
+// Define la ruta del stream de novedades del delivery
+func init() {
+	server.Router().GET("/v1/delivery/:deliveryId/stream", server.ValidateAuthentication, streamDelivery)
+}
+
+// Stream Server-Sent Events con los eventos y posiciones de un delivery.
+// Lo puede ver el dueño del delivery o un admin.
+//
+// Cada mensaje SSE lleva un id con la versión del último evento enviado: los eventos usan
+// "<version>" y las posiciones "<version>-<timestamp>". Al reconectar con Last-Event-ID se
+// reenvían solo los eventos posteriores a esa versión, si no viene se envía el historial completo.
+func streamDelivery(c *gin.Context) {
+	delivery, _, ok := findVisibleDelivery(c, c.Param("deliveryId"))
+	if !ok {
+		return
+	}
+	ctx := server.GinCtx(c)
+	logger := log.Get(ctx...)
+
+	// Primero la suscripción para no perder eventos que lleguen mientras se lee el historial
+	messages, unsubscribe := broadcast.Subscribe(delivery.DeliveryId)
+	defer unsubscribe()
+
+	lastVersion := parseLastEventId(c.GetHeader("Last-Event-ID"))
+	stream, err := events.FindDeliveryEventsByDeliveryId(delivery.DeliveryId, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	sequencer := broadcast.NewSequencer(lastVersion, func() ([]*events.Event, error) {
+		return events.FindDeliveryEventsByDeliveryId(delivery.DeliveryId, ctx...)
+	})
+	for _, e := range sequencer.Missing(stream, 0) {
+		writeSSE(c.Writer, strconv.FormatInt(e.Version, 10), "event", newDeliveryEventResponse(e))
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case m, open := <-messages:
+			if !open {
+				// El broadcaster desconectó el stream por lento, el cliente reconecta con Last-Event-ID
+				logger.Info("Stream desconectado por lento: ", delivery.DeliveryId)
+				return false
+			}
+			if m.Event != nil {
+				// Si el evento llegó antes que uno anterior se envían también los que faltan
+				pending, err := sequencer.Next(m.Event)
+				if err != nil {
+					logger.Error(err)
+					return false
+				}
+				for _, e := range pending {
+					writeSSE(w, strconv.FormatInt(e.Version, 10), "event", newDeliveryEventResponse(e))
+				}
+			}
+			if m.Ping != nil {
+				writeSSE(w, fmt.Sprintf("%d-%d", sequencer.LastVersion, m.Ping.Timestamp.UnixMilli()), "location", m.Ping)
+			}
+			return true
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// writeSSE escribe un mensaje Server-Sent Events
+func writeSSE(w io.Writer, id, event string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, body)
+}
+
+// parseLastEventId obtiene la versión del último evento recibido por el cliente
+func parseLastEventId(value string) int64 {
+	value, _, _ = strings.Cut(value, "-")
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return version
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"sync"
+
+	"deliverygo/broadcast"
+	"deliverygo/projections"
+	"deliverygo/tools/log"
+)
+
+// boardUpdate es una novedad para los tableros, con la proyección ya leída si es un evento
+type boardUpdate struct {
+	*broadcast.Message
+	Delivery *projections.DeliveryProjection
+}
+
+// boardFeed reparte las novedades del broadcaster entre los tableros conectados a la instancia.
+// Usa una sola suscripción y lee la proyección una vez por evento para todas las conexiones.
+type boardFeed struct {
+	mu      sync.Mutex
+	clients map[chan *boardUpdate]bool
+	running bool
+	load    func(deliveryId string) (*projections.DeliveryProjection, error)
+}
+
+var board = newBoardFeed(func(deliveryId string) (*projections.DeliveryProjection, error) {
+	return projections.FindByDeliveryId(deliveryId)
+})
+
+func newBoardFeed(load func(deliveryId string) (*projections.DeliveryProjection, error)) *boardFeed {
+	return &boardFeed{
+		clients: map[chan *boardUpdate]bool{},
+		load:    load,
+	}
+}
+
+// subscribe registra un tablero. El canal se cierra al llamar a la función de cancelación o si
+// el tablero no consume a tiempo, en ese caso el cliente debe reconectarse.
+func (f *boardFeed) subscribe() (<-chan *boardUpdate, func()) {
+	updates := make(chan *boardUpdate, boardBuffer)
+
+	f.mu.Lock()
+	f.clients[updates] = true
+	if !f.running {
+		f.running = true
+		messages, unsubscribe := broadcast.SubscribeAll()
+		go f.run(messages, unsubscribe)
+	}
+	f.mu.Unlock()
+
+	return updates, func() {
+		f.remove(updates)
+	}
+}
+
+func (f *boardFeed) remove(updates chan *boardUpdate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.clients[updates] {
+		delete(f.clients, updates)
+		close(updates)
+	}
+}
+
+// run reparte las novedades hasta que no quedan tableros conectados.
+// Si el broadcaster cierra la suscripción por lenta se desconectan todos los tableros,
+// al reconectar reciben un snapshot nuevo.
+func (f *boardFeed) run(messages <-chan *broadcast.Message, unsubscribe func()) {
+	defer unsubscribe()
+
+	for m := range messages {
+		if !f.hasClients() {
+			return
+		}
+
+		update := &boardUpdate{Message: m}
+		if m.Event != nil {
+			delivery, err := f.load(m.DeliveryId)
+			if err != nil {
+				log.Get().Error(err)
+				continue
+			}
+			update.Delivery = delivery
+		}
+		f.send(update)
+	}
+
+	f.closeAll()
+}
+
+// hasClients retorna true si hay tableros conectados, si no hay marca el feed como detenido
+// para que el próximo tablero lo vuelva a iniciar
+func (f *boardFeed) hasClients() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.clients) == 0 {
+		f.running = false
+		return false
+	}
+	return true
+}
+
+// send envía la novedad a todos los tableros, los que no consumen a tiempo se desconectan
+func (f *boardFeed) send(update *boardUpdate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for updates := range f.clients {
+		select {
+		case updates <- update:
+		default:
+			delete(f.clients, updates)
+			close(updates)
+		}
+	}
+}
+
+func (f *boardFeed) closeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for updates := range f.clients {
+		close(updates)
+	}
+	f.clients = map[chan *boardUpdate]bool{}
+	f.running = false
+}
@@ -0,0 +1,283 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"deliverygo/events"
+	"deliverygo/locations"
+	"deliverygo/projections"
+	"deliverygo/rest/server"
+	"deliverygo/security"
+	"deliverygo/tools/env"
+	"deliverygo/tools/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Tiempos de la conexión del tablero: cuánto se espera el mensaje con el token, cada cuánto
+// se manda un ping, cuánto se espera el pong y cuánto puede tardar una escritura
+const (
+	boardAuthTimeout  = 10 * time.Second
+	boardPingPeriod   = 30 * time.Second
+	boardPongWait     = 60 * time.Second
+	boardWriteTimeout = 10 * time.Second
+)
+
+// Tipos de mensaje del tablero, auth lo envía el cliente y el resto el servidor
+const (
+	boardMessageAuth     = "auth"
+	boardMessageSnapshot = "snapshot"
+	boardMessageDelivery = "delivery"
+	boardMessageLocation = "location"
+)
+
+// Códigos de cierre cuando el token llega después del upgrade
+const (
+	boardCloseForbidden   = 4403
+	boardCloseAuthTimeout = 4408
+)
+
+// Subprotocolo del tablero. Los navegadores no pueden enviar headers en el handshake, el token
+// se puede enviar como un segundo subprotocolo "bearer.<token>".
+const (
+	boardProtocol       = "dispatch-board"
+	boardBearerProtocol = "bearer."
+)
+
+// Deliveries que se envían como máximo en el snapshot
+const boardSnapshotLimit = 500
+
+// Novedades que puede acumular un tablero lento antes de ser desconectado
+const boardBuffer = 64
+
+var boardUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{boardProtocol},
+	CheckOrigin: func(r *http.Request) bool {
+		return security.OriginAllowed(r, strings.Split(env.Get().BoardOrigins, ","))
+	},
+}
+
+// Define la ruta del tablero de despacho, se autentica en el handler porque el token puede
+// llegar después del upgrade
+func init() {
+	server.Router().GET("/v1/dispatch/board", dispatchBoard)
+}
+
+// Mensaje que se envía por el tablero de despacho.
+// snapshot lleva los deliveries activos, los más antiguos primero y con truncated si hay más de
+// los que se envían, delivery el estado actualizado de un delivery junto con el evento que lo
+// cambió y location una nueva posición del courier.
+type DispatchBoardMessage struct {
+	Type       string                 `json:"type"`
+	Deliveries []DeliveryResponse     `json:"deliveries,omitempty"`
+	Truncated  bool                   `json:"truncated,omitempty"`
+	Delivery   *DeliveryResponse      `json:"delivery,omitempty"`
+	Event      *DeliveryEventResponse `json:"event,omitempty"`
+	Location   *locations.Ping        `json:"location,omitempty"`
+}
+
+// Mensaje con el token que envía el cliente si no lo pudo enviar en el handshake
+type boardAuthMessage struct {
+	Type          string `json:"type"`
+	Authorization string `json:"authorization"`
+}
+
+// Tablero de despacho por WebSocket, solo para admins.
+// El token se toma del header Authorization, del subprotocolo "bearer.<token>" o, si no viene en
+// el handshake, del primer mensaje {"type": "auth", "authorization": "Bearer <token>"}.
+// Después del snapshot con los deliveries que no están en un estado terminal se envía cada
+// cambio de estado, asignación de courier o posición que se registre.
+func dispatchBoard(c *gin.Context) {
+	ctx := server.GinCtx(c)
+	logger := log.Get(ctx...)
+
+	var user *security.User
+	if authorization := boardAuthorization(c.Request); len(authorization) > 0 {
+		u, err := security.ValidateAuthorization(authorization)
+		if err != nil {
+			server.AbortWithError(c, err)
+			return
+		}
+		if !u.HasPermission(security.PermissionAdmin) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+			return
+		}
+		user = u
+	}
+
+	conn, err := boardUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade ya respondió el error al cliente
+		logger.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	if user == nil {
+		if user = authenticateBoard(conn); user == nil {
+			return
+		}
+	}
+
+	// Primero la suscripción para no perder cambios que lleguen mientras se arma el snapshot
+	updates, unsubscribe := board.subscribe()
+	defer unsubscribe()
+
+	snapshot, err := newBoardSnapshot(user, ctx...)
+	if err != nil {
+		logger.Error(err)
+		closeBoard(conn, websocket.CloseInternalServerErr, "Internal server error")
+		return
+	}
+	if err := writeBoardMessage(conn, snapshot); err != nil {
+		logger.Info("Tablero desconectado: ", err)
+		return
+	}
+
+	closed := readBoardConnection(conn)
+
+	ping := time.NewTicker(boardPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case u, open := <-updates:
+			if !open {
+				// El tablero se desconectó por lento, el cliente reconecta y recibe un snapshot nuevo
+				logger.Info("Tablero desconectado por lento")
+				return
+			}
+			message, ok := newBoardMessage(u, user)
+			if !ok {
+				continue
+			}
+			if err := writeBoardMessage(conn, message); err != nil {
+				logger.Info("Tablero desconectado: ", err)
+				return
+			}
+		case <-ping.C:
+			deadline := time.Now().Add(boardWriteTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				logger.Info("Tablero desconectado: ", err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// boardAuthorization retorna el token del handshake en formato "Bearer <token>", vacío si no vino
+func boardAuthorization(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); len(header) > 0 {
+		return header
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(protocol, boardBearerProtocol) {
+			return "Bearer " + strings.TrimPrefix(protocol, boardBearerProtocol)
+		}
+	}
+	return ""
+}
+
+// authenticateBoard espera el mensaje auth y valida que el usuario sea admin.
+// Si no se puede autenticar cierra la conexión y retorna nil.
+func authenticateBoard(conn *websocket.Conn) *security.User {
+	conn.SetReadDeadline(time.Now().Add(boardAuthTimeout))
+
+	m := boardAuthMessage{}
+	if err := conn.ReadJSON(&m); err != nil {
+		closeBoard(conn, boardCloseAuthTimeout, "Authentication timeout")
+		return nil
+	}
+	if m.Type != boardMessageAuth {
+		closeBoard(conn, boardCloseForbidden, "Expected auth")
+		return nil
+	}
+
+	user, err := security.ValidateAuthorization(m.Authorization)
+	if err != nil || !user.HasPermission(security.PermissionAdmin) {
+		closeBoard(conn, boardCloseForbidden, "Forbidden")
+		return nil
+	}
+	return user
+}
+
+// newBoardSnapshot arma el snapshot con los deliveries activos, hasta boardSnapshotLimit
+func newBoardSnapshot(user *security.User, ctx ...interface{}) (DispatchBoardMessage, error) {
+	status := []string{}
+	for _, s := range events.ActiveStatuses() {
+		status = append(status, string(s))
+	}
+
+	// Se pide uno más para saber si quedaron deliveries afuera
+	active, err := projections.FindByStatus(status, boardSnapshotLimit+1, ctx...)
+	if err != nil {
+		return DispatchBoardMessage{}, err
+	}
+
+	snapshot := DispatchBoardMessage{Type: boardMessageSnapshot, Deliveries: []DeliveryResponse{}}
+	if len(active) > boardSnapshotLimit {
+		active = active[:boardSnapshotLimit]
+		snapshot.Truncated = true
+	}
+	for _, delivery := range active {
+		snapshot.Deliveries = append(snapshot.Deliveries, newDeliveryResponse(delivery, user))
+	}
+	return snapshot, nil
+}
+
+// newBoardMessage arma el mensaje del tablero para una novedad del feed.
+// Los eventos se envían con la proyección ya actualizada, retorna false si no hay nada que enviar.
+func newBoardMessage(u *boardUpdate, user *security.User) (DispatchBoardMessage, bool) {
+	if u.Ping != nil {
+		return DispatchBoardMessage{Type: boardMessageLocation, Location: u.Ping}, true
+	}
+	if u.Event == nil || u.Delivery == nil {
+		return DispatchBoardMessage{}, false
+	}
+
+	response := newDeliveryResponse(u.Delivery, user)
+	event := newDeliveryEventResponse(u.Event)
+	return DispatchBoardMessage{Type: boardMessageDelivery, Delivery: &response, Event: &event}, true
+}
+
+// closeBoard cierra la conexión con el código y motivo indicados
+func closeBoard(conn *websocket.Conn, code int, reason string) {
+	deadline := time.Now().Add(boardWriteTimeout)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
+
+// readBoardConnection lee la conexión para procesar pongs y cierres del cliente.
+// El canal que retorna se cierra cuando la conexión se corta.
+func readBoardConnection(conn *websocket.Conn) <-chan struct{} {
+	closed := make(chan struct{})
+
+	conn.SetReadDeadline(time.Now().Add(boardPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(boardPongWait))
+	})
+
+	go func() {
+		defer close(closed)
+		for {
+			// El tablero es de solo lectura, los mensajes del cliente se descartan
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	return closed
+}
+
+// writeBoardMessage envía un mensaje JSON con tiempo límite de escritura
+func writeBoardMessage(conn *websocket.Conn, message DispatchBoardMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(boardWriteTimeout))
+	return conn.WriteJSON(message)
+}
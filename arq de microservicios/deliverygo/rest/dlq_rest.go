@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"net/http"
+
+	"deliverygo/deadletter"
+	"deliverygo/rest/server"
+	"deliverygo/tools/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define las rutas de administración de las dead letter queues, solo admins.
+// :queue es el nombre de la cola original, por ejemplo create_delivery.
+func init() {
+	server.Router().GET("/v1/dlq/:queue/messages", server.ValidateAuthentication, listDeadLetters)
+	server.Router().POST("/v1/dlq/:queue/messages/:messageId/requeue", server.ValidateAuthentication, requeueDeadLetter)
+	server.Router().POST("/v1/dlq/:queue/messages/:messageId/discard", server.ValidateAuthentication, discardDeadLetter)
+	server.Router().POST("/v1/dlq/:queue/requeue", server.ValidateAuthentication, requeueAllDeadLetters)
+	server.Router().GET("/v1/dlq/:queue/audit", server.ValidateAuthentication, listDeadLetterAudit)
+}
+
+// Estructura para el cuerpo de la solicitud de descarte de un mensaje
+type DiscardDeadLetterRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Listar los mensajes de la DLQ con los datos de la falla, los mensajes quedan en la DLQ
+func listDeadLetters(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	v := errs.NewValidation()
+	limit := parseLimitParam(c, v)
+	if v.HasErrors() {
+		server.AbortWithError(c, v)
+		return
+	}
+
+	messages, err := deadletter.List(c.Param("queue"), int(limit), server.GinCtx(c)...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// Devolver un mensaje de la DLQ a su cola original
+func requeueDeadLetter(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+	user, _ := getAuthenticatedUser(c)
+
+	message, err := deadletter.Requeue(c.Param("queue"), c.Param("messageId"), user.ID, server.GinCtx(c)...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// Devolver a la cola original todos los mensajes de la DLQ
+func requeueAllDeadLetters(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+	user, _ := getAuthenticatedUser(c)
+
+	messages, err := deadletter.RequeueAll(c.Param("queue"), user.ID, server.GinCtx(c)...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// Descartar un mensaje de la DLQ indicando el motivo
+func discardDeadLetter(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+	user, _ := getAuthenticatedUser(c)
+
+	var req DiscardDeadLetterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	message, err := deadletter.Discard(c.Param("queue"), c.Param("messageId"), req.Reason, user.ID, server.GinCtx(c)...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// Listar las acciones ejecutadas sobre la DLQ, de la más nueva a la más vieja
+func listDeadLetterAudit(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El usuario no tiene permisos de admin"})
+		return
+	}
+
+	v := errs.NewValidation()
+	limit := parseLimitParam(c, v)
+	if v.HasErrors() {
+		server.AbortWithError(c, v)
+		return
+	}
+
+	records, err := deadletter.FindAudit(c.Param("queue"), limit, server.GinCtx(c)...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit": records})
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"deliverygo/commands"
+	"deliverygo/projections"
+	"deliverygo/rest/server"
+	"deliverygo/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryETag es el ETag del delivery, la versión del último evento de su stream
+func deliveryETag(delivery *projections.DeliveryProjection) string {
+	return `"` + strconv.FormatInt(delivery.Version, 10) + `"`
+}
+
+// respondDelivery responde el delivery con su ETag
+func respondDelivery(c *gin.Context, delivery *projections.DeliveryProjection, user *security.User) {
+	c.Header("ETag", deliveryETag(delivery))
+	c.JSON(http.StatusOK, newDeliveryResponse(delivery, user))
+}
+
+// notModified responde 304 si el If-None-Match del request coincide con la versión actual del delivery
+func notModified(c *gin.Context, delivery *projections.DeliveryProjection) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	etag := deliveryETag(delivery)
+	if !matchesETag(header, etag) {
+		return false
+	}
+	c.Header("ETag", etag)
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// matchesETag retorna true si alguno de los valores del If-None-Match es "*" o etag.
+// La comparación es débil, ignora el prefijo W/.
+func matchesETag(header, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// commandCtx arma las dependencias del comando. Si el request trae If-Match agrega la versión
+// esperada, y el comando responde 412 si el delivery cambió desde que el cliente lo leyó.
+// Si el If-Match no es una versión válida responde 412 y retorna false.
+func commandCtx(c *gin.Context) ([]interface{}, bool) {
+	ctx := server.GinCtx(c)
+
+	version, present, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": commands.ErrPreconditionFailed.Error()})
+		return nil, false
+	}
+	if !present {
+		return ctx, true
+	}
+
+	return append(ctx, commands.ExpectedVersion(version)), true
+}
+
+// parseIfMatch retorna la versión del If-Match. present es false si no viene o es "*",
+// que no restringen la versión.
+func parseIfMatch(header string) (version int64, present bool, err error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err = strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return version, true, nil
+}
@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"deliverygo/commands"
+	"deliverygo/projections"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int64
+		present bool
+		err     bool
+	}{
+		{"", 0, false, false},
+		{"  ", 0, false, false},
+		{"*", 0, false, false},
+		{`"3"`, 3, true, false},
+		{`W/"12"`, 12, true, false},
+		{` "7" `, 7, true, false},
+		{"5", 5, true, false},
+		{`"abc"`, 0, false, true},
+		{`"1", "2"`, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		version, present, err := parseIfMatch(tt.header)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v, esperaba error %v", tt.header, err, tt.err)
+			continue
+		}
+		if version != tt.version || present != tt.present {
+			t.Errorf("%q: (%d, %v), esperaba (%d, %v)", tt.header, version, present, tt.version, tt.present)
+		}
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	etag := deliveryETag(&projections.DeliveryProjection{Version: 4})
+	if etag != `"4"` {
+		t.Fatalf("deliveryETag = %s", etag)
+	}
+
+	for header, want := range map[string]bool{
+		`"4"`:        true,
+		`W/"4"`:      true,
+		`"3", "4"`:   true,
+		"*":          true,
+		`"3"`:        false,
+		`"40"`:       false,
+		"4":          false,
+		`"3", W/"5"`: false,
+	} {
+		if got := matchesETag(header, etag); got != want {
+			t.Errorf("matchesETag(%q) = %v, esperaba %v", header, got, want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	delivery := &projections.DeliveryProjection{Version: 4}
+
+	for header, want := range map[string]bool{
+		"":       false,
+		`"3"`:    false,
+		`"4"`:    true,
+		`W/"4"`:  true,
+		"*":      true,
+		`"1", *`: true,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/v1/delivery/order/o1", nil)
+		if header != "" {
+			c.Request.Header.Set("If-None-Match", header)
+		}
+
+		if got := notModified(c, delivery); got != want {
+			t.Errorf("notModified(%q) = %v, esperaba %v", header, got, want)
+			continue
+		}
+		if want && (c.Writer.Status() != http.StatusNotModified || w.Header().Get("ETag") != `"4"`) {
+			t.Errorf("notModified(%q): status %d, ETag %q", header, c.Writer.Status(), w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestCommandCtx(t *testing.T) {
+	tests := []struct {
+		header  string
+		ok      bool
+		version int64
+		present bool
+	}{
+		{"", true, 0, false},
+		{"*", true, 0, false},
+		{`"7"`, true, 7, true},
+		{`W/"7"`, true, 7, true},
+		{`"abc"`, false, 0, false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("PUT", "/v1/delivery/d1", nil)
+		if tt.header != "" {
+			c.Request.Header.Set("If-Match", tt.header)
+		}
+
+		ctx, ok := commandCtx(c)
+		if ok != tt.ok {
+			t.Errorf("%q: ok = %v, esperaba %v", tt.header, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			if w.Code != http.StatusPreconditionFailed {
+				t.Errorf("%q: status %d, esperaba 412", tt.header, w.Code)
+			}
+			continue
+		}
+
+		var version commands.ExpectedVersion
+		present := false
+		for _, o := range ctx {
+			if v, found := o.(commands.ExpectedVersion); found {
+				version, present = v, true
+			}
+		}
+		if present != tt.present || int64(version) != tt.version {
+			t.Errorf("%q: ExpectedVersion (%d, %v), esperaba (%d, %v)", tt.header, version, present, tt.version, tt.present)
+		}
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("ETag", `"4"`)
+	header.Set("Content-Type", "application/json")
+
+	want := map[string]string{"ETag": `"4"`}
+	if got := responseHeaders(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("responseHeaders() = %v, esperaba %v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"deliverygo/idempotency"
+	"deliverygo/rest/server"
+	"deliverygo/tools/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers de idempotencia
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// replayedHeaders son los headers de la respuesta que se guardan con la clave y se repiten
+// en los reintentos, el ETag permite seguir usando If-Match con la respuesta repetida
+var replayedHeaders = []string{"ETag", "Location"}
+
+// Operaciones sobre las claves de idempotencia, son variables para reemplazarlas en los tests
+var (
+	reserveKey  = idempotency.Reserve
+	completeKey = idempotency.Complete
+	releaseKey  = idempotency.Release
+)
+
+// idempotent permite reintentar un request que modifica datos sin aplicarlo dos veces.
+// Si el request trae Idempotency-Key se guarda la respuesta y los reintentos con la misma
+// clave la reciben sin volver a ejecutar el handler. Una clave usada con otro request
+// responde 422 y una clave cuyo request todavía se está procesando responde 409, hasta
+// idempotency.PendingTimeout, después el reintento se ejecuta.
+// Las respuestas 5xx no se guardan, el cliente puede reintentar con la misma clave.
+func idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if len(key) == 0 {
+		c.Next()
+		return
+	}
+	if len(key) > 255 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key inválida"})
+		return
+	}
+
+	user, err := getAuthenticatedUser(c)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	runIdempotent(c, user.ID, key)
+}
+
+// runIdempotent reserva la clave del usuario y ejecuta el request, o responde con lo guardado
+// si la clave ya se usó
+func runIdempotent(c *gin.Context, userId, key string) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx := server.GinCtx(c)
+	hash := requestHash(c, body)
+	record, reserved, err := reserveKey(userId, key, hash, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	if !reserved {
+		switch {
+		case record.RequestHash != hash:
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key ya usada con otro request"})
+		case record.Status != idempotency.RecordStatusCompleted:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "El request con esta Idempotency-Key se está procesando"})
+		default:
+			for name, value := range record.Headers {
+				c.Header(name, value)
+			}
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(record.ResponseStatus, record.ContentType, record.ResponseBody)
+			c.Abort()
+		}
+		return
+	}
+
+	// Si el handler entra en pánico se libera la clave para que se pueda reintentar
+	defer func() {
+		if r := recover(); r != nil {
+			releaseKey(record.ID, ctx...)
+			panic(r)
+		}
+	}()
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	if recorder.Status() >= http.StatusInternalServerError {
+		releaseKey(record.ID, ctx...)
+		return
+	}
+	if err := completeKey(record.ID, recorder.Status(), recorder.Header().Get("Content-Type"), responseHeaders(recorder.Header()), recorder.body.Bytes(), ctx...); err != nil {
+		log.Get(ctx...).Error("No se pudo guardar la respuesta de la clave ", key, ": ", err)
+	}
+}
+
+// responseHeaders retorna los replayedHeaders presentes en la respuesta
+func responseHeaders(header http.Header) map[string]string {
+	result := map[string]string{}
+	for _, name := range replayedHeaders {
+		if value := header.Get(name); len(value) > 0 {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// requestHash identifica el request por método, ruta, If-Match y body.
+// Con otro If-Match el request aplica sobre otra versión del delivery, no es un reintento.
+func requestHash(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	hash.Write([]byte(c.GetHeader("If-Match") + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder copia la respuesta que se envía al cliente
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"deliverygo/idempotency"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRequestHashIncludesIfMatch(t *testing.T) {
+	hash := func(ifMatch string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("PUT", "/v1/delivery/d1", nil)
+		if ifMatch != "" {
+			c.Request.Header.Set("If-Match", ifMatch)
+		}
+		return requestHash(c, []byte(`{"status":"cancelled"}`))
+	}
+
+	if hash(`"3"`) != hash(`"3"`) {
+		t.Error("el mismo request debería tener el mismo hash")
+	}
+	if hash(`"3"`) == hash(`"4"`) || hash("") == hash(`"3"`) {
+		t.Error("requests con otro If-Match deberían tener otro hash")
+	}
+}
+
+// fakeKeys reemplaza el store de claves de idempotencia durante un test
+type fakeKeys struct {
+	existing  *idempotency.Record // Clave ya usada, nil si se reserva
+	completed *idempotency.Record // Respuesta guardada con completeKey
+	released  bool
+}
+
+func (f *fakeKeys) install(t *testing.T) {
+	reserve, complete, release := reserveKey, completeKey, releaseKey
+	t.Cleanup(func() { reserveKey, completeKey, releaseKey = reserve, complete, release })
+
+	reserveKey = func(userId, key, requestHash string, deps ...interface{}) (*idempotency.Record, bool, error) {
+		if f.existing != nil {
+			return f.existing, false, nil
+		}
+		return &idempotency.Record{ID: primitive.NewObjectID(), UserId: userId, Key: key, RequestHash: requestHash, Status: idempotency.RecordStatusPending}, true, nil
+	}
+	completeKey = func(id primitive.ObjectID, status int, contentType string, headers map[string]string, body []byte, deps ...interface{}) error {
+		f.completed = &idempotency.Record{ID: id, ResponseStatus: status, ContentType: contentType, Headers: headers, ResponseBody: body}
+		return nil
+	}
+	releaseKey = func(id primitive.ObjectID, deps ...interface{}) error {
+		f.released = true
+		return nil
+	}
+}
+
+const idempotentBody = `{"status":"cancelled"}`
+
+// serveIdempotent ejecuta un request con clave contra un handler que responde status
+func serveIdempotent(status int) (*httptest.ResponseRecorder, *bool) {
+	gin.SetMode(gin.TestMode)
+	called := false
+	router := gin.New()
+	router.PUT("/v1/delivery/:deliveryId",
+		func(c *gin.Context) { runIdempotent(c, "u1", "k1") },
+		func(c *gin.Context) {
+			called = true
+			c.Header("ETag", `"2"`)
+			c.JSON(status, gin.H{"status": "cancelled"})
+		})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/delivery/d1", strings.NewReader(idempotentBody)))
+	return w, &called
+}
+
+// hashOf calcula el hash del request que arma serveIdempotent
+func hashOf(t *testing.T) string {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("PUT", "/v1/delivery/d1", nil)
+	return requestHash(c, []byte(idempotentBody))
+}
+
+func TestIdempotentReservedKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *idempotency.Record
+		status   int
+		replayed bool
+	}{
+		{"otro request", &idempotency.Record{RequestHash: "otro", Status: idempotency.RecordStatusCompleted}, http.StatusUnprocessableEntity, false},
+		{"pendiente", &idempotency.Record{RequestHash: hashOf(t), Status: idempotency.RecordStatusPending}, http.StatusConflict, false},
+		{"completado", &idempotency.Record{
+			RequestHash:    hashOf(t),
+			Status:         idempotency.RecordStatusCompleted,
+			ResponseStatus: http.StatusOK,
+			ContentType:    "application/json",
+			Headers:        map[string]string{"ETag": `"2"`},
+			ResponseBody:   []byte(`{"status":"cancelled"}`),
+		}, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &fakeKeys{existing: tt.existing}
+			keys.install(t)
+
+			w, called := serveIdempotent(http.StatusOK)
+			if *called {
+				t.Error("el handler no se debería ejecutar con una clave usada")
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, se esperaba %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get(idempotencyReplayedHeader) == "true"; got != tt.replayed {
+				t.Errorf("%s = %v, se esperaba %v", idempotencyReplayedHeader, got, tt.replayed)
+			}
+			if tt.replayed && (w.Header().Get("ETag") != `"2"` || w.Body.String() != `{"status":"cancelled"}`) {
+				t.Errorf("respuesta repetida = %v %s", w.Header(), w.Body.String())
+			}
+			if keys.completed != nil || keys.released {
+				t.Error("una clave usada no se debería modificar")
+			}
+		})
+	}
+}
+
+func TestIdempotentNewKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		released bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"error del cliente", http.StatusConflict, false},
+		{"error del servidor", http.StatusInternalServerError, true},
+		{"servicio no disponible", http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &fakeKeys{}
+			keys.install(t)
+
+			w, called := serveIdempotent(tt.status)
+			if !*called {
+				t.Fatal("el handler se debería ejecutar con una clave nueva")
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, se esperaba %d", w.Code, tt.status)
+			}
+			if keys.released != tt.released {
+				t.Errorf("released = %v, se esperaba %v", keys.released, tt.released)
+			}
+			if tt.released {
+				if keys.completed != nil {
+					t.Error("las respuestas 5xx no se deberían guardar")
+				}
+				return
+			}
+			if keys.completed == nil || keys.completed.ResponseStatus != tt.status ||
+				keys.completed.Headers["ETag"] != `"2"` || string(keys.completed.ResponseBody) != w.Body.String() {
+				t.Errorf("respuesta guardada = %+v", keys.completed)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"deliverygo/locations"
+	"deliverygo/projections"
+	"deliverygo/rest/server"
+	"deliverygo/security"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Cantidad de posiciones del recorrido que se devuelven por defecto y como máximo
+const (
+	defaultTrailLimit = 50
+	maxTrailLimit     = 500
+)
+
+// Define las rutas de seguimiento del delivery
+func init() {
+	server.Router().GET("/v1/delivery/:deliveryId/location", server.ValidateAuthentication, getDeliveryLocation)
+}
+
+// Estructura para la respuesta de la posición del delivery
+type DeliveryLocationResponse struct {
+	DeliveryId string            `json:"deliveryId"`
+	Status     string            `json:"status"`
+	Latest     *locations.Ping   `json:"latest"` // Última posición conocida, null si no hay
+	Trail      []*locations.Ping `json:"trail"`  // Recorrido reciente, de la más reciente a la más vieja
+}
+
+// Obtener la última posición y el recorrido reciente de un delivery.
+// Lo puede ver el dueño del delivery o un admin, ?limit indica el largo del recorrido.
+func getDeliveryLocation(c *gin.Context) {
+	delivery, _, ok := findVisibleDelivery(c, c.Param("deliveryId"))
+	if !ok {
+		return
+	}
+
+	limit := int64(defaultTrailLimit)
+	if value, err := strconv.ParseInt(c.Query("limit"), 10, 64); err == nil && value > 0 {
+		limit = min(value, maxTrailLimit)
+	}
+
+	ctx := server.GinCtx(c)
+	trail, err := locations.FindTrail(delivery.DeliveryId, limit, ctx...)
+	if err != nil {
+		server.AbortWithError(c, err)
+		return
+	}
+
+	result := DeliveryLocationResponse{
+		DeliveryId: delivery.DeliveryId,
+		Status:     delivery.Status,
+		Trail:      trail,
+	}
+	if len(trail) > 0 {
+		result.Latest = trail[0]
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// findVisibleDelivery busca el delivery y verifica que el usuario logueado sea el dueño o admin.
+// Si no se puede ver responde el error y retorna false.
+func findVisibleDelivery(c *gin.Context, deliveryId string) (*projections.DeliveryProjection, *security.User, bool) {
+	ctx := server.GinCtx(c)
+	delivery, err := projections.FindByDeliveryId(deliveryId, ctx...)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery NO encontrado"})
+		return nil, nil, false
+	}
+	if err != nil {
+		server.AbortWithError(c, err)
+		return nil, nil, false
+	}
+
+	user, ok := canSeeDelivery(c, delivery.UserId)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Delivery NO corresponde al usuario autenticado"})
+		return nil, nil, false
+	}
+
+	return delivery, user, true
+}
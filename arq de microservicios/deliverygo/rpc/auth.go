@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"deliverygo/security"
+	"deliverygo/tools/env"
+	"deliverygo/tools/log"
+
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// contextKey claves de los valores que se guardan en el contexto de cada llamada
+type contextKey string
+
+const (
+	userContextKey contextKey = "user"
+	depsContextKey contextKey = "deps"
+)
+
+// serviceCredential es un servicio habilitado a usar la interfaz gRPC
+type serviceCredential struct {
+	name  string
+	token string
+}
+
+// serviceCredentials lee las credenciales configuradas en ServiceTokens ("servicio:token,servicio:token")
+func serviceCredentials() []serviceCredential {
+	result := []serviceCredential{}
+	for _, entry := range strings.Split(env.Get().ServiceTokens, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if ok && len(name) > 0 && len(token) > 0 {
+			result = append(result, serviceCredential{name: name, token: token})
+		}
+	}
+	return result
+}
+
+// authenticate valida el token de servicio del metadata authorization ("Bearer <token>")
+// y retorna el usuario que representa al servicio
+func authenticate(ctx context.Context) (*security.User, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	token := values[0][7:]
+
+	for _, credential := range serviceCredentials() {
+		if subtle.ConstantTimeCompare([]byte(credential.token), []byte(token)) == 1 {
+			return newServiceUser(credential.name), nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+}
+
+// newServiceUser arma el usuario de un servicio. Los servicios son de confianza y tienen
+// permisos de admin sobre los deliveries.
+func newServiceUser(name string) *security.User {
+	return &security.User{
+		ID:          "service:" + name,
+		Name:        name,
+		Login:       name,
+		Permissions: []string{security.PermissionAdmin},
+	}
+}
+
+// onBehalfOfAuthorization metadata con el token del usuario en nombre del cual actúa el servicio
+const onBehalfOfAuthorization = "on-behalf-of-authorization"
+
+// onBehalfOfUser valida el token del usuario que reenvía el servicio ("Bearer <token>") y verifica
+// que corresponda al usuario indicado. Retorna el usuario con sus permisos.
+func onBehalfOfUser(ctx context.Context, userId string) (*security.User, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(onBehalfOfAuthorization)
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.PermissionDenied, "on_behalf_of requires the user token in "+onBehalfOfAuthorization)
+	}
+
+	user, err := security.ValidateAuthorization(values[0])
+	if err != nil || user.ID != userId {
+		return nil, status.Error(codes.PermissionDenied, "Invalid on_behalf_of user token")
+	}
+	return user, nil
+}
+
+// newContext autentica la llamada y agrega al contexto el usuario del servicio y el logger
+func newContext(ctx context.Context, method string) (context.Context, error) {
+	user, err := authenticate(ctx)
+	if err != nil {
+		log.Get().WithField(log.LOG_FIELD_CONTROLLER, "gRPC").Info("Llamada no autenticada: ", method)
+		return nil, err
+	}
+
+	correlationId := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(log.LOG_FIELD_CORRELATION_ID); len(values) > 0 {
+			correlationId = values[0]
+		}
+	}
+	if len(correlationId) == 0 {
+		correlationId = uuid.NewV4().String()
+	}
+
+	logger := log.Get().
+		WithField(log.LOG_FIELD_CONTROLLER, "gRPC").
+		WithField(log.LOG_FIELD_CORRELATION_ID, correlationId).
+		WithField(log.LOG_FIELD_USER_ID, user.ID).
+		WithField(log.LOG_FIELD_HTTP_PATH, method)
+
+	ctx = context.WithValue(ctx, userContextKey, user)
+	return context.WithValue(ctx, depsContextKey, []interface{}{logger}), nil
+}
+
+// unaryInterceptor autentica las llamadas simples
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := newContext(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor autentica las llamadas con stream
+func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := newContext(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream reemplaza el contexto del stream por el autenticado
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// currentUser retorna el usuario del servicio que hace la llamada
+func currentUser(ctx context.Context) *security.User {
+	user, _ := ctx.Value(userContextKey).(*security.User)
+	return user
+}
+
+// deps retorna las dependencias (logger) de la llamada
+func deps(ctx context.Context) []interface{} {
+	deps, _ := ctx.Value(depsContextKey).([]interface{})
+	return deps
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/rpc/deliverypb"
+	"deliverygo/tools/errs"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Equivalencias entre los estados del delivery y el enum del proto
+var statusToProto = map[events.DeliveryStatus]deliverypb.DeliveryStatus{
+	events.DeliveryStatusConfirmed: deliverypb.DeliveryStatus_DELIVERY_STATUS_CONFIRMED,
+	events.DeliveryStatusCancelled: deliverypb.DeliveryStatus_DELIVERY_STATUS_CANCELLED,
+	events.DeliveryStatusOnTheGo:   deliverypb.DeliveryStatus_DELIVERY_STATUS_ON_THE_GO,
+	events.DeliveryStatusDelivered: deliverypb.DeliveryStatus_DELIVERY_STATUS_DELIVERED,
+}
+
+// fromProtoStatus convierte el estado del proto, UNSPECIFIED o desconocido retorna ""
+func fromProtoStatus(s deliverypb.DeliveryStatus) events.DeliveryStatus {
+	for status, value := range statusToProto {
+		if value == s {
+			return status
+		}
+	}
+	return ""
+}
+
+func toProtoDelivery(delivery *projections.DeliveryProjection) *deliverypb.Delivery {
+	result := &deliverypb.Delivery{
+		DeliveryId:      delivery.DeliveryId,
+		OrderId:         delivery.OrderId,
+		UserId:          delivery.UserId,
+		Status:          statusToProto[events.DeliveryStatus(delivery.Status)],
+		CourierId:       delivery.CourierId,
+		CourierAccepted: delivery.CourierAccepted,
+		CreatedAt:       timestamppb.New(delivery.CreatedAt),
+		LastModified:    timestamppb.New(delivery.LastModified),
+	}
+	if delivery.Address != nil {
+		result.Address = &deliverypb.Address{
+			Recipient:    delivery.Address.Recipient,
+			Street:       delivery.Address.Street,
+			City:         delivery.Address.City,
+			PostalCode:   delivery.Address.PostalCode,
+			Country:      delivery.Address.Country,
+			Phone:        delivery.Address.Phone,
+			Instructions: delivery.Address.Instructions,
+		}
+	}
+	return result
+}
+
+func toProtoEvent(e *events.Event) *deliverypb.DeliveryEvent {
+	return &deliverypb.DeliveryEvent{
+		DeliveryId: e.DeliveryId,
+		Version:    e.Version,
+		Type:       string(e.Type),
+		Status:     statusToProto[e.DeliveryStatus],
+		UserId:     e.ActorId(),
+		Timestamp:  timestamppb.New(e.Timestamp()),
+		Created:    timestamppb.New(e.Created),
+	}
+}
+
+// toStatusError traduce los errores de los comandos y repositorios a códigos gRPC
+func toStatusError(err error) error {
+	switch e := err.(type) {
+	case *events.InvalidTransitionError:
+		return status.Error(codes.FailedPrecondition, e.Error())
+	case errs.RestError:
+		code, ok := restCodes[e.Status()]
+		if !ok {
+			code = codes.Unknown
+		}
+		return status.Error(code, e.Error())
+	case errs.Validation:
+		return status.Error(codes.InvalidArgument, e.Error())
+	}
+	if err == mongo.ErrNoDocuments {
+		return status.Error(codes.NotFound, errs.NotFound.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// restCodes equivalencias de los códigos http de errs.RestError, los que no están son codes.Unknown
+var restCodes = map[int]codes.Code{
+	400: codes.InvalidArgument,
+	401: codes.Unauthenticated,
+	403: codes.PermissionDenied,
+	404: codes.NotFound,
+	409: codes.Aborted,
+	500: codes.Internal,
+}
@@ -0,0 +1,239 @@
+// Interfaz gRPC de deliveries para la comunicación entre servicios.
+// Usa las mismas proyecciones, eventos y comandos que la API REST, autenticando
+// con credenciales de servicio en lugar de tokens de usuario.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	"deliverygo/broadcast"
+	"deliverygo/commands"
+	"deliverygo/events"
+	"deliverygo/projections"
+	"deliverygo/rpc/deliverypb"
+	"deliverygo/tools/env"
+	"deliverygo/tools/errs"
+	"deliverygo/tools/log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Cantidad de deliveries que devuelve ListDeliveries por defecto y como máximo
+const (
+	defaultListLimit = 100
+	maxListLimit     = 500
+)
+
+// Start inicia el servidor gRPC en el puerto GrpcPort, en todas las interfaces para que lo
+// alcancen los otros servicios. Todas las llamadas se autentican con los tokens de ServiceTokens,
+// sin tokens configurados no inicia. Con GrpcTLSCert y GrpcTLSKey escucha con TLS (mTLS si además
+// se indica GrpcClientCA), sin certificado los tokens viajan en texto plano por la red interna.
+func Start() {
+	logger := log.Get().WithField(log.LOG_FIELD_CONTROLLER, "gRPC")
+
+	config := env.Get()
+	if len(serviceCredentials()) == 0 {
+		logger.Error("SERVICE_TOKENS no tiene credenciales, gRPC no inicia")
+		return
+	}
+
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.StreamInterceptor(streamInterceptor),
+	}
+
+	address := fmt.Sprintf(":%d", config.GrpcPort)
+	if len(config.GrpcTLSCert) > 0 || len(config.GrpcTLSKey) > 0 {
+		tlsConfig, err := serverTLSConfig(config.GrpcTLSCert, config.GrpcTLSKey, config.GrpcClientCA)
+		if err != nil {
+			logger.Error("No se pudo configurar TLS, gRPC no inicia: ", err)
+			return
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else {
+		logger.Warn("gRPC sin TLS, los tokens de servicio viajan en texto plano")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	server := grpc.NewServer(options...)
+	deliverypb.RegisterDeliveryServiceServer(server, &deliveryService{})
+
+	logger.Info("gRPC escuchando en ", address)
+	if err := server.Serve(listener); err != nil {
+		logger.Error(err)
+	}
+}
+
+// serverTLSConfig carga el certificado del servidor. Si se indica clientCA exige y verifica
+// el certificado del cliente contra esa CA.
+func serverTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(clientCA) == 0 {
+		return result, nil
+	}
+
+	pem, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s no contiene certificados", clientCA)
+	}
+	result.ClientCAs = pool
+	result.ClientAuth = tls.RequireAndVerifyClientCert
+	return result, nil
+}
+
+// deliveryService implementa deliverypb.DeliveryServiceServer
+type deliveryService struct {
+	deliverypb.UnimplementedDeliveryServiceServer
+}
+
+func (s *deliveryService) GetDelivery(ctx context.Context, req *deliverypb.GetDeliveryRequest) (*deliverypb.Delivery, error) {
+	delivery, err := projections.FindByDeliveryId(req.DeliveryId, deps(ctx)...)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toProtoDelivery(delivery), nil
+}
+
+func (s *deliveryService) GetDeliveryByOrder(ctx context.Context, req *deliverypb.GetDeliveryByOrderRequest) (*deliverypb.Delivery, error) {
+	delivery, err := projections.FindDeliveryByOrderId(req.OrderId, deps(ctx)...)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toProtoDelivery(delivery), nil
+}
+
+// ListDeliveries retorna una página de deliveries, las siguientes se piden con next_page_token
+func (s *deliveryService) ListDeliveries(ctx context.Context, req *deliverypb.ListDeliveriesRequest) (*deliverypb.ListDeliveriesResponse, error) {
+	filter := projections.DeliveryFilter{
+		UserId:    req.UserId,
+		OrderId:   req.OrderId,
+		CourierId: req.CourierId,
+		Limit:     defaultListLimit,
+		Cursor:    req.PageToken,
+	}
+	for _, s := range req.Status {
+		status := fromProtoStatus(s)
+		if status == "" {
+			return nil, toStatusError(errs.NewValidation().Add("status", fmt.Sprintf("invalid status: %s", s)))
+		}
+		filter.Status = append(filter.Status, string(status))
+	}
+	if req.Limit > 0 {
+		filter.Limit = int64(min(req.Limit, maxListLimit))
+	}
+
+	page, err := projections.FindDeliveries(filter, deps(ctx)...)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	result := &deliverypb.ListDeliveriesResponse{
+		Deliveries:    []*deliverypb.Delivery{},
+		NextPageToken: page.NextCursor,
+	}
+	for _, d := range page.Deliveries {
+		result.Deliveries = append(result.Deliveries, toProtoDelivery(d))
+	}
+	return result, nil
+}
+
+// ChangeStatus aplica el cambio a nombre del servicio, o del usuario indicado en on_behalf_of.
+// En ese caso el servicio reenvía el token del usuario y el cambio se autoriza con sus permisos,
+// el evento queda a nombre del usuario y registra el servicio.
+func (s *deliveryService) ChangeStatus(ctx context.Context, req *deliverypb.ChangeStatusRequest) (*deliverypb.Delivery, error) {
+	user := currentUser(ctx)
+	commandDeps := deps(ctx)
+	if req.OnBehalfOf != "" {
+		onBehalfOf, err := onBehalfOfUser(ctx, req.OnBehalfOf)
+		if err != nil {
+			log.Get(deps(ctx)...).Info("on_behalf_of no autorizado: ", req.OnBehalfOf)
+			return nil, err
+		}
+		commandDeps = append(commandDeps, commands.ServiceCaller(user.ID))
+		user = onBehalfOf
+	}
+
+	delivery, err := commands.ChangeStatus(req.DeliveryId, fromProtoStatus(req.Status), user, commandDeps...)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return toProtoDelivery(delivery), nil
+}
+
+// WatchDelivery envía el historial posterior a from_version y luego los eventos nuevos, en orden de versión.
+// Si el cliente no lee a tiempo el stream termina con Unavailable y debe reconectar
+// indicando la versión del último evento recibido.
+func (s *deliveryService) WatchDelivery(req *deliverypb.WatchDeliveryRequest, stream deliverypb.DeliveryService_WatchDeliveryServer) error {
+	ctx := stream.Context()
+
+	// Primero la suscripción para no perder eventos que lleguen mientras se lee el historial
+	messages, unsubscribe := broadcast.Subscribe(req.DeliveryId)
+	defer unsubscribe()
+
+	history, err := events.FindDeliveryEventsByDeliveryId(req.DeliveryId, deps(ctx)...)
+	if err != nil {
+		return toStatusError(err)
+	}
+	if len(history) == 0 {
+		return toStatusError(errs.NotFound)
+	}
+
+	sequencer := broadcast.NewSequencer(req.FromVersion, func() ([]*events.Event, error) {
+		return events.FindDeliveryEventsByDeliveryId(req.DeliveryId, deps(ctx)...)
+	})
+	for _, e := range sequencer.Missing(history, 0) {
+		if err := stream.Send(toProtoEvent(e)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case m, open := <-messages:
+			if !open {
+				log.Get(deps(ctx)...).Info("Stream desconectado por lento: ", req.DeliveryId)
+				return status.Error(codes.Unavailable, "Stream desconectado por lento, reconectar con from_version")
+			}
+			if m.Event == nil {
+				continue
+			}
+			// Si el evento llegó antes que uno anterior se envían también los que faltan
+			pending, err := sequencer.Next(m.Event)
+			if err != nil {
+				return toStatusError(err)
+			}
+			for _, e := range pending {
+				if err := stream.Send(toProtoEvent(e)); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package security
+
+import (
+	"deliverygo/tools/errs"
+)
+
+// ValidateAuthorization valida el valor del header Authorization ("Bearer <token>") y retorna el usuario
+func ValidateAuthorization(header string) (*User, error) {
+	if len(header) <= 7 {
+		return nil, errs.Unauthorized
+	}
+
+	return Validate(header[7:])
+}
+
+// Permisos de usuario que maneja el servicio
+const (
+	PermissionAdmin   = "admin"
+	PermissionCourier = "courier"
+)
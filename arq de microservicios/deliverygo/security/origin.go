@@ -0,0 +1,31 @@
+package security
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginAllowed valida el Origin de un upgrade a WebSocket. Acepta las conexiones sin Origin, que
+// no vienen de un navegador, las del mismo host que el servidor y las de los orígenes permitidos.
+// Evita que otro sitio abra la conexión con las credenciales del usuario.
+func OriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSpace(a), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,3 +7,13 @@ type User struct {
 	Permissions []string `json:"permissions"`
 	Login       string   `json:"login"  validate:"required"`
 }
+
+// HasPermission retorna true si el usuario tiene el permiso indicado
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -9,10 +9,17 @@ import (
 type Configuration struct {
 	Port              int    `json:"port"`
 	GqlPort           int    `json:"gqlPort"`
+	GrpcPort          int    `json:"grpcPort"`
 	RabbitURL         string `json:"rabbitUrl"`
 	MongoURL          string `json:"mongoUrl"`
 	SecurityServerURL string `json:"securityServerUrl"`
 	FluentUrl         string `json:"fluentUrl"`
+	BoardOrigins      string `json:"boardOrigins"`   // Orígenes de otros hosts que pueden abrir el tablero de despacho y las subscriptions, separados por comas
+	ServiceTokens     string `json:"serviceTokens"`  // Credenciales de servicio para gRPC, "servicio:token,servicio:token"
+	GrpcTLSCert       string `json:"grpcTlsCert"`    // Certificado del servidor gRPC, sin certificado escucha sin TLS
+	GrpcTLSKey        string `json:"grpcTlsKey"`     // Clave privada del certificado
+	GrpcClientCA      string `json:"grpcClientCa"`   // CA de los certificados de cliente, si se indica se exige mTLS
+	RequireAddress    bool   `json:"requireAddress"` // Rechazar los create_delivery sin dirección, una vez que orders la envía
 }
 
 var config *Configuration
@@ -32,6 +39,7 @@ func load() *Configuration {
 	result := &Configuration{
 		Port:              3004,
 		GqlPort:           4004,
+		GrpcPort:          5004,
 		RabbitURL:         "amqp://localhost",
 		MongoURL:          "mongodb://localhost:27017",
 		SecurityServerURL: "http://localhost:3000",
@@ -62,6 +70,38 @@ func load() *Configuration {
 		}
 	}
 
+	if value := os.Getenv("DISPATCH_BOARD_ORIGINS"); len(value) > 0 {
+		result.BoardOrigins = value
+	}
+
+	if value := os.Getenv("GRPC_PORT"); len(value) > 0 {
+		if intVal, err := strconv.Atoi(value); err == nil {
+			result.GrpcPort = intVal
+		}
+	}
+
+	if value := os.Getenv("SERVICE_TOKENS"); len(value) > 0 {
+		result.ServiceTokens = value
+	}
+
+	if value := os.Getenv("GRPC_TLS_CERT"); len(value) > 0 {
+		result.GrpcTLSCert = value
+	}
+
+	if value := os.Getenv("GRPC_TLS_KEY"); len(value) > 0 {
+		result.GrpcTLSKey = value
+	}
+
+	if value := os.Getenv("GRPC_CLIENT_CA"); len(value) > 0 {
+		result.GrpcClientCA = value
+	}
+
+	if value := os.Getenv("CREATE_DELIVERY_REQUIRE_ADDRESS"); len(value) > 0 {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			result.RequireAddress = boolVal
+		}
+	}
+
 	if value := os.Getenv("AUTH_SERVICE_URL"); len(value) > 0 {
 		result.SecurityServerURL = value
 	}
@@ -5,6 +5,9 @@ package errs
 // Unauthorized el usuario no esta autorizado al recurso
 var Unauthorized = NewRestError(401, "Unauthorized")
 
+// Forbidden el usuario no tiene permisos para la operación
+var Forbidden = NewRestError(403, "Forbidden")
+
 // NotFound cuando un registro no se encuentra en la db
 var NotFound = NewRestError(404, "Document not found")
 
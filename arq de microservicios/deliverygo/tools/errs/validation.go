@@ -8,7 +8,8 @@ import (
 // Validation es un error de validaciones de parameteros o de campos
 type Validation interface {
 	Add(path string, message string) Validation
-	Error() string //devuelve una repres del error como un json
+	HasErrors() bool //true si se agregó algún error
+	Error() string   //devuelve una repres del error como un json
 }
 
 func NewValidation() Validation {
@@ -39,6 +40,11 @@ func (e *ValidationErr) Add(path string, message string) Validation {
 	return e
 }
 
+// HasErrors retorna true si se agregó algún error
+func (e *ValidationErr) HasErrors() bool {
+	return len(e.Messages) > 0
+}
+
 // errField define un campo inválido. path y mensaje de error
 type errField struct {
 	Path    string `json:"path"` //campo o parametro